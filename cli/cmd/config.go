@@ -0,0 +1,104 @@
+/*
+ * Copyright (c) 2026, Psiphon Inc.
+ * All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/Psiphon-Inc/conduit/cli/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var configVerifyPath string
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect Psiphon network configs",
+}
+
+var configVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Check that a config file matches the embedded config",
+	Long: `Compare a Psiphon config file against the config embedded in this binary.
+
+Whitespace and key order are ignored. Keys whose values differ are listed,
+but their values are not printed because they may be secret. Exits with an
+error if the configs differ.`,
+	Args: cobra.NoArgs,
+	RunE: runConfigVerify,
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configVerifyCmd)
+
+	configVerifyCmd.Flags().StringVarP(&configVerifyPath, "psiphon-config", "c", "", "path to the Psiphon config file to compare")
+	_ = configVerifyCmd.MarkFlagFilename("psiphon-config", "json")
+	_ = configVerifyCmd.MarkFlagRequired("psiphon-config")
+}
+
+func runConfigVerify(cmd *cobra.Command, args []string) error {
+	if !config.HasEmbeddedConfig() {
+		return fmt.Errorf("this build has no embedded config to compare against")
+	}
+
+	data, err := os.ReadFile(configVerifyPath)
+	if err != nil {
+		return fmt.Errorf("failed to read psiphon config: %w", err)
+	}
+	embedded := config.GetEmbeddedPsiphonConfig()
+
+	diffs, err := config.DiffPsiphonConfigs(embedded, data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", configVerifyPath, err)
+	}
+	embeddedInfo, err := config.DescribePsiphonConfig(embedded)
+	if err != nil {
+		return fmt.Errorf("failed to read embedded config: %w", err)
+	}
+	fileInfo, err := config.DescribePsiphonConfig(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", configVerifyPath, err)
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintf(writer, "Embedded Config:\tsha256:%s\n", embeddedInfo.Fingerprint)
+	_, _ = fmt.Fprintf(writer, "%s:\tsha256:%s\n", configVerifyPath, fileInfo.Fingerprint)
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+
+	if len(diffs) == 0 {
+		fmt.Println("\nThe configs are equivalent.")
+		return nil
+	}
+
+	fmt.Printf("\n%d keys differ:\n", len(diffs))
+	writer = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, diff := range diffs {
+		_, _ = fmt.Fprintf(writer, "  %s\t%s\n", diff.Kind, diff.Key)
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	return fmt.Errorf("%s differs from the embedded config", configVerifyPath)
+}
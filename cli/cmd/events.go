@@ -0,0 +1,204 @@
+/*
+ * Copyright (c) 2026, Psiphon Inc.
+ * All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/Psiphon-Inc/conduit/cli/internal/control"
+	"github.com/Psiphon-Inc/conduit/cli/internal/logging"
+	"github.com/spf13/cobra"
+)
+
+// eventsRetryInterval is how often a lost control socket is redialed
+const eventsRetryInterval = time.Second
+
+// eventTypes are the event types that can be passed to --filter
+var eventTypes = []string{
+	control.EventClientConnect,
+	control.EventClientDisconnect,
+	control.EventInstanceState,
+	control.EventLimits,
+	control.EventDropped,
+}
+
+var (
+	eventsControlSocket string
+	eventsFilter        []string
+	eventsJSON          bool
+	eventsReconnect     time.Duration
+)
+
+var eventsCmd = &cobra.Command{
+	Use:   "events",
+	Short: "Follow the event stream of a running Conduit",
+	Long: `Follow the events published on the control socket of a running Conduit
+service (start Conduit with --control-socket).
+
+Events are printed one per line, or as the raw JSON lines with --json. When
+the service restarts its socket goes away for a moment; events reconnects
+and keeps following unless the socket is gone for longer than --reconnect.
+Events published while reconnecting are missed.`,
+	Args: cobra.NoArgs,
+	RunE: runEvents,
+}
+
+func init() {
+	rootCmd.AddCommand(eventsCmd)
+
+	eventsCmd.Flags().StringVar(&eventsControlSocket, "control-socket", "conduit.sock", "control socket of the running service (relative paths are in the data dir)")
+	eventsCmd.Flags().StringSliceVar(&eventsFilter, "filter", nil, "only show these event types ("+strings.Join(eventTypes, ", ")+")")
+	_ = eventsCmd.RegisterFlagCompletionFunc("filter", cobra.FixedCompletions(eventTypes, cobra.ShellCompDirectiveNoFileComp))
+	eventsCmd.Flags().BoolVar(&eventsJSON, "json", false, "print events as JSON lines")
+	eventsCmd.Flags().DurationVar(&eventsReconnect, "reconnect", 30*time.Second, "how long to keep redialing a lost control socket before giving up")
+}
+
+func runEvents(cmd *cobra.Command, args []string) error {
+	filter := make(map[string]bool)
+	for _, eventType := range eventsFilter {
+		if !isEventType(eventType) {
+			return fmt.Errorf("unknown event type %q (use %s)", eventType, strings.Join(eventTypes, ", "))
+		}
+		filter[eventType] = true
+	}
+
+	path := resolveDataPath(eventsControlSocket)
+
+	sub, err := control.Subscribe(path, 5*time.Second)
+	if err != nil {
+		return err
+	}
+
+	// A signal closes the current subscription, which unblocks Next
+	var current atomic.Pointer[control.Subscription]
+	current.Store(sub)
+	done := make(chan struct{})
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
+	go func() {
+		<-sigChan
+		close(done)
+		_ = current.Load().Close()
+	}()
+
+	for {
+		event, err := sub.Next()
+		if err != nil {
+			_ = sub.Close()
+			select {
+			case <-done:
+				return nil
+			default:
+			}
+			fmt.Fprintf(os.Stderr, "Lost control socket (%v), reconnecting...\n", err)
+			sub, err = resubscribe(path, done)
+			if err != nil || sub == nil {
+				return err
+			}
+			current.Store(sub)
+			// A signal between resubscribe and Store closed the old
+			// subscription, so close the new one here
+			select {
+			case <-done:
+				_ = sub.Close()
+				return nil
+			default:
+			}
+			fmt.Fprintln(os.Stderr, "Reconnected to control socket")
+			continue
+		}
+
+		if len(filter) > 0 && !filter[event.Type] {
+			continue
+		}
+		if eventsJSON {
+			line, err := json.Marshal(event)
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(line))
+		} else {
+			fmt.Println(formatEvent(event))
+		}
+	}
+}
+
+// resubscribe redials path until it succeeds, --reconnect passes, or done
+// is closed (which returns a nil subscription and no error)
+func resubscribe(path string, done <-chan struct{}) (*control.Subscription, error) {
+	deadline := time.Now().Add(eventsReconnect)
+	for {
+		sub, err := control.Subscribe(path, eventsRetryInterval)
+		if err == nil {
+			return sub, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("control socket gone for %s: %w", eventsReconnect, err)
+		}
+		select {
+		case <-done:
+			return nil, nil
+		case <-time.After(eventsRetryInterval):
+		}
+	}
+}
+
+func isEventType(eventType string) bool {
+	for _, t := range eventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// formatEvent renders an event as its timestamp, type and sorted key=value data
+func formatEvent(event control.Event) string {
+	var b strings.Builder
+	timestamp := event.Timestamp
+	if t, err := time.Parse(time.RFC3339, event.Timestamp); err == nil {
+		timestamp = t.Local().Format(logging.TimeFormat)
+	}
+	fmt.Fprintf(&b, "%s %-17s", timestamp, event.Type)
+
+	keys := make([]string, 0, len(event.Data))
+	for key := range event.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := event.Data[key]
+		// JSON numbers decode as float64; keep byte counts out of exponent form
+		if f, ok := value.(float64); ok && f == float64(int64(f)) {
+			value = int64(f)
+		}
+		fmt.Fprintf(&b, " %s=%v", key, value)
+	}
+	return strings.TrimRight(b.String(), " ")
+}
@@ -0,0 +1,124 @@
+/*
+ * Copyright (c) 2026, Psiphon Inc.
+ * All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/Psiphon-Inc/conduit/cli/internal/config"
+	"github.com/Psiphon-Inc/conduit/cli/internal/deploy"
+	"github.com/spf13/cobra"
+)
+
+var (
+	generateInstances     int
+	generateMaxClients    int
+	generateBandwidth     float64
+	generateMetricsAddr   string
+	generatePsiphonConfig string
+	generateBinaryPath    string
+	generateImage         string
+)
+
+var generateCmd = &cobra.Command{
+	Use:   "generate",
+	Short: "Generate deployment files for systemd or Docker",
+	Long: `Generate ready-to-use deployment files that run conduit start with the
+given limits. The file is written to stdout.`,
+}
+
+var generateSystemdCmd = &cobra.Command{
+	Use:   "systemd",
+	Short: "Generate a systemd unit file",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// systemd runs from /, so the config path must be absolute
+		if generatePsiphonConfig != "" {
+			abs, err := filepath.Abs(generatePsiphonConfig)
+			if err != nil {
+				return fmt.Errorf("failed to resolve psiphon config path: %w", err)
+			}
+			generatePsiphonConfig = abs
+		}
+		return runGenerate(cmd, deploy.SystemdUnit)
+	},
+}
+
+var generateDockerfileCmd = &cobra.Command{
+	Use:   "dockerfile",
+	Short: "Generate a Dockerfile based on the published image",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runGenerate(cmd, deploy.Dockerfile)
+	},
+}
+
+var generateComposeCmd = &cobra.Command{
+	Use:   "compose",
+	Short: "Generate a docker compose file",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runGenerate(cmd, deploy.Compose)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(generateCmd)
+	generateCmd.AddCommand(generateSystemdCmd, generateDockerfileCmd, generateComposeCmd)
+
+	generateCmd.PersistentFlags().IntVar(&generateInstances, "instances", 1, "number of conduit instances to run")
+	generateCmd.PersistentFlags().IntVarP(&generateMaxClients, "max-clients", "m", config.DefaultMaxClients, "maximum number of proxy clients per instance (1-1000)")
+	generateCmd.PersistentFlags().Float64VarP(&generateBandwidth, "bandwidth", "b", config.DefaultBandwidthMbps, "bandwidth limit in Mbps (-1 for unlimited)")
+	generateCmd.PersistentFlags().StringVar(&generateMetricsAddr, "metrics-addr", "", "address for Prometheus metrics (compose numbers ports up from this one per instance)")
+	generateCmd.PersistentFlags().StringVarP(&generatePsiphonConfig, "psiphon-config", "c", "", "path to Psiphon network config file (default: config embedded in the binary or image)")
+	_ = generateCmd.MarkPersistentFlagFilename("psiphon-config", "json")
+	generateSystemdCmd.Flags().StringVar(&generateBinaryPath, "binary", deploy.DefaultBinaryPath, "path to the conduit binary")
+	generateDockerfileCmd.Flags().StringVar(&generateImage, "image", deploy.DefaultImage, "base image")
+	generateComposeCmd.Flags().StringVar(&generateImage, "image", deploy.DefaultImage, "image to run")
+}
+
+func runGenerate(cmd *cobra.Command, generate func(deploy.Options) (string, error)) error {
+	opts := deploy.Options{
+		Instances:         generateInstances,
+		MetricsAddr:       generateMetricsAddr,
+		PsiphonConfigPath: generatePsiphonConfig,
+		BinaryPath:        generateBinaryPath,
+		Image:             generateImage,
+	}
+	// Only pass limits the user chose, so the generated flags match
+	if cmd.Flags().Changed("max-clients") {
+		if generateMaxClients < 1 {
+			return fmt.Errorf("max-clients must be between 1 and %d", config.MaxClientsLimit)
+		}
+		opts.MaxClients = generateMaxClients
+	}
+	if cmd.Flags().Changed("bandwidth") {
+		opts.BandwidthMbps = generateBandwidth
+		opts.BandwidthSet = true
+	}
+
+	output, err := generate(opts)
+	if err != nil {
+		return err
+	}
+	fmt.Print(output)
+	return nil
+}
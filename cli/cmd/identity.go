@@ -0,0 +1,200 @@
+/*
+ * Copyright (c) 2026, Psiphon Inc.
+ * All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Psiphon-Inc/conduit/cli/internal/conduit"
+	"github.com/Psiphon-Inc/conduit/cli/internal/config"
+	"github.com/Psiphon-Inc/conduit/cli/internal/control"
+	"github.com/Psiphon-Inc/conduit/cli/internal/crypto"
+	"github.com/spf13/cobra"
+)
+
+var identityControlSocket string
+
+var identityCmd = &cobra.Command{
+	Use:   "identity",
+	Short: "Inspect this station's proxy identity",
+}
+
+var identityReputationCmd = &cobra.Command{
+	Use:   "reputation",
+	Short: "Show what is known about this station's broker reputation",
+	Long: `Show the indicators the broker's trust in this station builds on.
+
+The Psiphon broker does not report a reputation or score to proxies, so this
+shows the continuity signals instead: how long the identity has existed and
+whether the service is running and live now.`,
+	Args: cobra.NoArgs,
+	RunE: runIdentityReputation,
+}
+
+var identityDuplicatesCmd = &cobra.Command{
+	Use:   "duplicates <data-dir>...",
+	Short: "Find data directories that share a proxy identity",
+	Long: `Check the keys in several data directories, one per Conduit instance, and
+report any that have the same proxy ID, e.g. because a key file was copied.
+
+Instances with the same identity look like a single proxy to the broker and
+share its reputation. Exits with an error if duplicates are found.`,
+	Args: cobra.MinimumNArgs(2),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return nil, cobra.ShellCompDirectiveFilterDirs
+	},
+	RunE: runIdentityDuplicates,
+}
+
+func init() {
+	rootCmd.AddCommand(identityCmd)
+	identityCmd.AddCommand(identityReputationCmd)
+	identityCmd.AddCommand(identityDuplicatesCmd)
+
+	identityReputationCmd.Flags().StringVar(&identityControlSocket, "control-socket", "conduit.sock", "control socket of the running service (relative paths are in the data dir)")
+}
+
+func runIdentityReputation(cmd *cobra.Command, args []string) error {
+	dataDir := GetDataDir()
+
+	kp, _, err := config.LoadKey(dataDir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			fmt.Println("Start your station first to create a key")
+			return errors.New("missing key")
+		}
+		return fmt.Errorf("failed to load key: %w", err)
+	}
+	proxyID, err := crypto.KeyPairToCurve25519Base64(kp)
+	if err != nil {
+		return fmt.Errorf("failed to derive proxy id: %w", err)
+	}
+
+	// The key file is written once, when the identity is created
+	identityAge := "unknown"
+	if info, err := os.Stat(config.KeyPath(dataDir)); err == nil {
+		created := info.ModTime()
+		identityAge = fmt.Sprintf("%s (since %s)", formatAge(time.Since(created)), created.Format("2006-01-02"))
+	}
+
+	service := "not running (or started without --control-socket)"
+	uptime := "-"
+	if line, err := control.Query(resolveDataPath(identityControlSocket), "status", time.Second); err == nil {
+		var status conduit.StatusJSON
+		if err := json.Unmarshal(line, &status); err != nil {
+			return fmt.Errorf("invalid status response: %w", err)
+		}
+		service = "starting (not yet live with the broker)"
+		if status.IsLive {
+			service = "live"
+		}
+		uptime = formatAge(time.Duration(status.UptimeSeconds) * time.Second)
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintf(writer, "Proxy ID:\t%s\n", proxyID)
+	_, _ = fmt.Fprintf(writer, "Identity age:\t%s\n", identityAge)
+	_, _ = fmt.Fprintf(writer, "Service:\t%s\n", service)
+	_, _ = fmt.Fprintf(writer, "Uptime:\t%s\n", uptime)
+	_ = writer.Flush()
+
+	fmt.Println()
+	fmt.Println("The broker does not expose a reputation score, so no recovery estimate")
+	fmt.Println("can be given. Reputation builds on a stable identity that stays online:")
+	fmt.Println("keep the key file, and avoid --ephemeral and long gaps in uptime.")
+
+	return nil
+}
+
+func runIdentityDuplicates(cmd *cobra.Command, args []string) error {
+	var order []string
+	dirsByID := make(map[string][]string)
+	seen := make(map[string]bool)
+	checked := 0
+
+	for _, dir := range args {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return err
+		}
+		if seen[abs] {
+			continue
+		}
+		seen[abs] = true
+
+		kp, _, err := config.LoadKey(dir)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				fmt.Printf("%s: no key yet, skipped\n", dir)
+				continue
+			}
+			return fmt.Errorf("%s: %w", dir, err)
+		}
+		proxyID, err := crypto.KeyPairToCurve25519Base64(kp)
+		if err != nil {
+			return fmt.Errorf("%s: failed to derive proxy id: %w", dir, err)
+		}
+		if _, ok := dirsByID[proxyID]; !ok {
+			order = append(order, proxyID)
+		}
+		dirsByID[proxyID] = append(dirsByID[proxyID], dir)
+		checked++
+	}
+
+	duplicates := 0
+	for _, proxyID := range order {
+		dirs := dirsByID[proxyID]
+		if len(dirs) < 2 {
+			continue
+		}
+		duplicates++
+		fmt.Printf("Proxy ID %s is used by:\n", proxyID)
+		for _, dir := range dirs {
+			fmt.Printf("  %s\n", dir)
+		}
+	}
+
+	if duplicates > 0 {
+		fmt.Println()
+		fmt.Println("Keep the key in one directory of each group, and move the key file out of")
+		fmt.Println("the others so they create new identities on their next start.")
+		return fmt.Errorf("%d proxy identities are shared by more than one data directory", duplicates)
+	}
+	fmt.Printf("No duplicate identities among %d data directories\n", checked)
+	return nil
+}
+
+// formatAge formats a duration in days and hours, or minutes when shorter
+func formatAge(d time.Duration) string {
+	switch {
+	case d >= 24*time.Hour:
+		return fmt.Sprintf("%dd %dh", int(d.Hours())/24, int(d.Hours())%24)
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh %dm", int(d.Hours()), int(d.Minutes())%60)
+	default:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	}
+}
@@ -0,0 +1,109 @@
+/*
+ * Copyright (c) 2026, Psiphon Inc.
+ * All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Psiphon-Inc/conduit/cli/internal/config"
+	"github.com/Psiphon-Inc/conduit/cli/internal/probe"
+	"github.com/spf13/cobra"
+)
+
+var (
+	probePsiphonConfig string
+	probeTimeout       time.Duration
+)
+
+var probeBrokerCmd = &cobra.Command{
+	Use:   "probe-broker",
+	Short: "Check that the broker endpoints are reachable",
+	Long: `Connect to each broker endpoint in the Psiphon config and complete a TLS
+handshake, reporting the latency of each. Nothing is sent to the broker, so
+the proxy does not register and its reputation is not affected.
+
+This uses Go's standard TLS stack rather than the fingerprints the proxy
+uses, so it shows that the network path works, not that the broker accepts
+the proxy.`,
+	Args: cobra.NoArgs,
+	RunE: runProbeBroker,
+}
+
+func init() {
+	rootCmd.AddCommand(probeBrokerCmd)
+
+	probeBrokerCmd.Flags().StringVarP(&probePsiphonConfig, "psiphon-config", "c", "", "path to Psiphon network config file (default: config embedded in the binary)")
+	_ = probeBrokerCmd.MarkFlagFilename("psiphon-config", "json")
+	probeBrokerCmd.Flags().DurationVar(&probeTimeout, "timeout", 10*time.Second, "timeout for each endpoint")
+}
+
+func runProbeBroker(cmd *cobra.Command, args []string) error {
+	var data []byte
+	switch {
+	case probePsiphonConfig != "":
+		var err error
+		data, err = os.ReadFile(probePsiphonConfig)
+		if err != nil {
+			return fmt.Errorf("failed to read psiphon config: %w", err)
+		}
+	case config.HasEmbeddedConfig():
+		data = config.GetEmbeddedPsiphonConfig()
+	default:
+		return fmt.Errorf("psiphon config required: use --psiphon-config, or build with embedded config")
+	}
+
+	endpoints, err := probe.Endpoints(data)
+	if err != nil {
+		return err
+	}
+	results := probe.ProbeAll(context.Background(), endpoints, probeTimeout)
+
+	reachable := 0
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(writer, "PROVIDER\tADDRESS\tTCP\tTLS\tRESULT")
+	for _, result := range results {
+		tcp, tls, outcome := "-", "-", "ok"
+		if result.TCPLatency > 0 {
+			tcp = result.TCPLatency.Round(time.Millisecond).String()
+		}
+		if result.TLSLatency > 0 {
+			tls = result.TLSLatency.Round(time.Millisecond).String()
+		}
+		if result.Err != nil {
+			outcome = result.Err.Error()
+		} else {
+			reachable++
+		}
+		_, _ = fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\n", result.ProviderID, result.Address, tcp, tls, outcome)
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+
+	if reachable == 0 {
+		return fmt.Errorf("no broker endpoint is reachable")
+	}
+	fmt.Printf("\n%d of %d endpoints reachable\n", reachable, len(results))
+	return nil
+}
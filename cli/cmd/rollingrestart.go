@@ -0,0 +1,204 @@
+/*
+ * Copyright (c) 2026, Psiphon Inc.
+ * All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Psiphon-Inc/conduit/cli/internal/conduit"
+	"github.com/Psiphon-Inc/conduit/cli/internal/control"
+	"github.com/spf13/cobra"
+)
+
+// rollingRestartPoll is how often a restarting instance's status is checked
+const rollingRestartPoll = time.Second
+
+// Restart orders for --order
+const (
+	orderGiven       = "given"
+	orderLeastLoaded = "least-loaded"
+	orderMostLoaded  = "most-loaded"
+)
+
+var (
+	rollingRestartWait    time.Duration
+	rollingRestartTimeout time.Duration
+	rollingRestartOrder   string
+)
+
+var rollingRestartCmd = &cobra.Command{
+	Use:   "rolling-restart <control-socket>...",
+	Short: "Restart Conduit instances one at a time",
+	Long: `Restart running Conduit instances one at a time through their control
+sockets (conduit start --control-socket), so only one instance's capacity is
+missing at any moment.
+
+Each instance reloads its configuration, as on SIGHUP, and must be live with
+the broker again within --timeout before the next one is restarted. Clients
+connected to an instance are dropped when it restarts. The rollout stops at
+the first instance that is not live beforehand or doesn't come back.
+
+Instances are restarted in the order given, or with --order least-loaded
+(most-loaded) by their connected clients when the rollout starts, so the
+fewest (or most) clients are dropped first. Ties keep the given order.`,
+	Args: cobra.MinimumNArgs(1),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"sock"}, cobra.ShellCompDirectiveFilterFileExt
+	},
+	RunE: runRollingRestart,
+}
+
+func init() {
+	rootCmd.AddCommand(rollingRestartCmd)
+
+	rollingRestartCmd.Flags().DurationVar(&rollingRestartWait, "wait", 30*time.Second, "pause after an instance is live again before restarting the next")
+	rollingRestartCmd.Flags().DurationVar(&rollingRestartTimeout, "timeout", 10*time.Minute, "how long an instance has to come back live before the rollout is aborted")
+	rollingRestartCmd.Flags().StringVar(&rollingRestartOrder, "order", orderGiven, "restart order: given, least-loaded or most-loaded (by connected clients)")
+	_ = rollingRestartCmd.RegisterFlagCompletionFunc("order", cobra.FixedCompletions(
+		[]string{orderGiven, orderLeastLoaded, orderMostLoaded}, cobra.ShellCompDirectiveNoFileComp))
+}
+
+func runRollingRestart(cmd *cobra.Command, args []string) error {
+	args, err := orderInstances(args, rollingRestartOrder)
+	if err != nil {
+		return err
+	}
+
+	for i, path := range args {
+		prefix := fmt.Sprintf("[%d/%d] %s:", i+1, len(args), path)
+
+		status, err := queryStatus(path)
+		if err != nil {
+			return fmt.Errorf("%s %w; aborting", prefix, err)
+		}
+		if !status.IsLive {
+			return fmt.Errorf("%s not live with the broker; aborting so capacity isn't reduced further", prefix)
+		}
+
+		line, err := control.Query(path, "reload", 5*time.Second)
+		if err != nil {
+			return fmt.Errorf("%s %w; aborting", prefix, err)
+		}
+		var response struct {
+			Error string `json:"error"`
+		}
+		if err := json.Unmarshal(line, &response); err != nil {
+			return fmt.Errorf("%s invalid reload response: %w", prefix, err)
+		}
+		if response.Error != "" {
+			return fmt.Errorf("%s reload refused: %s; aborting", prefix, response.Error)
+		}
+		fmt.Printf("%s restarting\n", prefix)
+
+		elapsed, err := waitForRestart(path, time.Now())
+		if err != nil {
+			return fmt.Errorf("%s %w; aborting", prefix, err)
+		}
+		fmt.Printf("%s live again after %s\n", prefix, elapsed.Round(time.Second))
+
+		if i < len(args)-1 && rollingRestartWait > 0 {
+			fmt.Printf("Waiting %s before the next instance\n", rollingRestartWait)
+			time.Sleep(rollingRestartWait)
+		}
+	}
+
+	fmt.Printf("Restarted %d instances\n", len(args))
+	return nil
+}
+
+// orderInstances returns the control socket paths in the order they should
+// be restarted
+func orderInstances(paths []string, order string) ([]string, error) {
+	switch order {
+	case orderGiven:
+		return paths, nil
+	case orderLeastLoaded, orderMostLoaded:
+	default:
+		return nil, fmt.Errorf("unknown order %q (use %s, %s or %s)", order, orderGiven, orderLeastLoaded, orderMostLoaded)
+	}
+
+	clients := make(map[string]int, len(paths))
+	for _, path := range paths {
+		status, err := queryStatus(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w; aborting", path, err)
+		}
+		clients[path] = status.ConnectedClients
+	}
+
+	ordered := append([]string(nil), paths...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		if order == orderMostLoaded {
+			return clients[ordered[i]] > clients[ordered[j]]
+		}
+		return clients[ordered[i]] < clients[ordered[j]]
+	})
+	fmt.Println("Restart order:")
+	for i, path := range ordered {
+		fmt.Printf("  %d. %s (%d clients)\n", i+1, path, clients[path])
+	}
+	return ordered, nil
+}
+
+// queryStatus returns the status of the instance at the control socket path
+func queryStatus(path string) (*conduit.StatusJSON, error) {
+	line, err := control.Query(path, "status", 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	var status conduit.StatusJSON
+	if err := json.Unmarshal(line, &status); err != nil {
+		return nil, fmt.Errorf("invalid status response: %w", err)
+	}
+	if err := status.CheckSchema(); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// waitForRestart waits until the instance at path is a new service (its
+// uptime began after requested) and is live with the broker, returning how
+// long that took
+func waitForRestart(path string, requested time.Time) (time.Duration, error) {
+	restarted := false
+	for time.Since(requested) < rollingRestartTimeout {
+		time.Sleep(rollingRestartPoll)
+
+		// The control socket is briefly gone while the service restarts
+		status, err := queryStatus(path)
+		if err != nil {
+			continue
+		}
+		if !restarted {
+			restarted = time.Duration(status.UptimeSeconds)*time.Second <= time.Since(requested)
+		}
+		if restarted && status.IsLive {
+			return time.Since(requested), nil
+		}
+	}
+
+	if restarted {
+		return 0, fmt.Errorf("restarted but not live with the broker after %s", rollingRestartTimeout)
+	}
+	return 0, fmt.Errorf("did not restart within %s (check its log: the new config may have been rejected)", rollingRestartTimeout)
+}
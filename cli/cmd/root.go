@@ -49,6 +49,7 @@ func Execute() error {
 func init() {
 	rootCmd.PersistentFlags().CountVarP(&verbosity, "verbose", "v", "increase verbosity (-v for verbose output)")
 	rootCmd.PersistentFlags().StringVarP(&dataDir, "data-dir", "d", "./data", "data directory (stores keys and state)")
+	_ = rootCmd.MarkPersistentFlagDirname("data-dir")
 }
 
 // Verbosity returns the verbosity level (0=normal, 1+=verbose)
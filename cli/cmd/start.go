@@ -20,30 +20,76 @@
 package cmd
 
 import (
+	"bufio"
 	"context"
 	"errors"
 	"fmt"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strings"
 	"syscall"
+	"text/tabwriter"
 	"time"
 
 	"github.com/Psiphon-Inc/conduit/cli/internal/conduit"
 	"github.com/Psiphon-Inc/conduit/cli/internal/config"
 	"github.com/Psiphon-Inc/conduit/cli/internal/logging"
+	"github.com/Psiphon-Inc/conduit/cli/internal/metrics"
+	"github.com/Psiphon-Inc/conduit/cli/internal/remoteconfig"
+	"github.com/Psiphon-Inc/conduit/cli/internal/systemd"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 )
 
 var (
 	maxClients        int
 	bandwidthMbps     float64
-	psiphonConfigPath string
+	psiphonConfigs    []string
+	configFailback    time.Duration
+	psiphonConfigFrom string
 	statsFilePath     string
 	geoEnabled        bool
 	metricsAddr       string
 	idleRestart       string
+	unhealthyRestart  string
 	compartment       string
+	controlSocket     string
+	dnsServer         string
+	checkOnReload     bool
+	ephemeral         bool
+	identitySeed      string
+	metricLabels      string
+	confirmStart      bool
+	fingerprintMode   string
+	memoryLimit       string
+	memoryPressure    float64
+	fdPressure        float64
+	minClientMbps     float64
+	reregister        bool
+	announceJitter    float64
+	logRateLimit      float64
+	readOnlyData      bool
+	strictPerms       bool
+	fixPerms          bool
+	relayDialTimeout  time.Duration
+	announceBackoff   time.Duration
+	brokerTimeout     time.Duration
+	metricsPrivacy    bool
+	noticeURL         string
+	noticeKey         string
+	leakWatchdog      bool
+	canary            bool
+	instanceName      string
+	pprofAddr         string
+	fileMode          string
+	influxAddr        string
+	influxInterval    time.Duration
+	remoteWriteURL    string
+	remoteInterval    time.Duration
+	remoteTokenFile   string
+	dashboardAddr     string
+	assumeYes         bool
 )
 
 var startCmd = &cobra.Command{
@@ -68,31 +114,95 @@ func init() {
 
 	startCmd.Flags().IntVarP(&maxClients, "max-clients", "m", config.DefaultMaxClients, "maximum number of proxy clients (1-1000)")
 	startCmd.Flags().Float64VarP(&bandwidthMbps, "bandwidth", "b", config.DefaultBandwidthMbps, "total bandwidth limit in Mbps (-1 for unlimited)")
+	startCmd.Flags().StringVar(&psiphonConfigFrom, "psiphon-config-from", "", "fetch the Psiphon config from a key-value store (vault://host/mount/data/path or consul://host/key)")
+	startCmd.Flags().Float64Var(&minClientMbps, "min-per-client-bandwidth", 0, "lower max clients so each client gets at least this many Mbps of the bandwidth limit (0 = off)")
 	startCmd.Flags().StringVarP(&statsFilePath, "stats-file", "s", "", "persist stats to JSON file (default: stats.json in data dir if flag used without value)")
 	startCmd.Flags().Lookup("stats-file").NoOptDefVal = "stats.json"
 	startCmd.Flags().BoolVar(&geoEnabled, "geo", false, "enable client location tracking (requires tcpdump, geoip-bin)")
-	startCmd.Flags().StringVar(&metricsAddr, "metrics-addr", "", "address for Prometheus metrics endpoint (e.g., :9090 or 127.0.0.1:9090)")
-	startCmd.Flags().StringVarP(&psiphonConfigPath, "psiphon-config", "c", "", "path to Psiphon network config file (JSON)")
+	startCmd.Flags().StringVar(&metricsAddr, "metrics-addr", "", "address for Prometheus metrics endpoint (e.g., :9090, 127.0.0.1:9090 or unix:metrics.sock)")
+	startCmd.Flags().StringArrayVarP(&psiphonConfigs, "psiphon-config", "c", nil, "path to Psiphon network config file (JSON); repeat to list failover configs in order")
+	startCmd.Flags().DurationVar(&configFailback, "psiphon-config-failback", time.Hour, "when running on a failover config, retry the first one after this long")
+	startCmd.Flags().StringVar(&instanceName, "instance-name", "", "name for this instance (e.g. us-east-primary), added to logs, status and a name label on all metrics")
+	startCmd.Flags().BoolVar(&canary, "canary", false, "mark this instance as a canary: adds canary=\"true\" to all metrics and reports it in status")
+	startCmd.Flags().BoolVar(&metricsPrivacy, "metrics-privacy", false, "merge countries with few clients and round client counts in exported metrics")
+	startCmd.Flags().StringVar(&metricLabels, "metric-labels", "", "constant labels added to all metrics (e.g., deployment=fleet-a,region=eu)")
 	startCmd.Flags().StringVar(&idleRestart, "idle-restart", "", "restart service after idle duration (e.g., 30m, 1h, 2h)")
+	startCmd.Flags().StringVar(&unhealthyRestart, "unhealthy-replace-after", "", "restart the service if it is not live with the broker after this long (e.g., 10m)")
 	startCmd.Flags().StringVar(&compartment, "compartment", "", "compartment name for private conduit pairing (clients must use the same name)")
+	startCmd.Flags().StringVar(&controlSocket, "control-socket", "", "serve status and events on a unix socket (default: conduit.sock in data dir if flag used without value)")
+	startCmd.Flags().Lookup("control-socket").NoOptDefVal = "conduit.sock"
+	startCmd.Flags().BoolVar(&checkOnReload, "config-check-on-reload", true, "validate the psiphon config on SIGHUP reload and keep the running config if it is invalid")
+	startCmd.Flags().BoolVar(&ephemeral, "ephemeral", false, "use a throwaway identity that is never saved (reputation does not accumulate)")
+	startCmd.Flags().StringVar(&identitySeed, "identity-seed", "", "TESTING ONLY: derive a new key from this hex seed and --instance-name instead of at random (keys are predictable)")
+	startCmd.Flags().BoolVar(&confirmStart, "confirm", false, "print the resolved limits and wait for 'yes' before starting (skipped if stdin is not a terminal)")
+	startCmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "answer yes to --confirm")
+	startCmd.Flags().StringVar(&fingerprintMode, "fingerprint-mode", config.FingerprintRandom, "TLS fingerprint for broker requests: random (per request), roundrobin (per restart) or fixed (per key)")
+	_ = startCmd.RegisterFlagCompletionFunc("fingerprint-mode", cobra.FixedCompletions(
+		[]string{config.FingerprintRandom, config.FingerprintRoundRobin, config.FingerprintFixed}, cobra.ShellCompDirectiveNoFileComp))
+	_ = startCmd.MarkFlagFilename("psiphon-config", "json")
+	_ = startCmd.MarkFlagFilename("stats-file", "json")
+	startCmd.Flags().StringVar(&memoryLimit, "memory-limit", "", "soft memory limit, e.g. 512MiB or 2GiB, or auto for 90% of the container's cgroup limit (the Go runtime collects harder near it)")
+	startCmd.Flags().Float64Var(&memoryPressure, "memory-pressure", config.DefaultMemoryPressure, "fraction of --memory-limit at which memory pressure is logged and counted")
+	startCmd.Flags().Float64Var(&fdPressure, "fd-pressure", config.DefaultFDPressure, "fraction of the open files limit at which file descriptor pressure is logged and counted")
+	startCmd.Flags().BoolVar(&reregister, "reregister-on-netchange", false, "reconnect to the broker when the host's network addresses change (e.g., floating IP failover)")
+	startCmd.Flags().Float64Var(&announceJitter, "announce-jitter", config.DefaultAnnounceJitter, "fraction (0-1) by which the delay between broker announcements varies (0 = fixed cadence)")
+	startCmd.Flags().Float64Var(&logRateLimit, "log-rate-limit", 0, "maximum times per second each distinct log message is printed, ignoring numbers; the rest are counted and summarized (0 = unlimited)")
+	startCmd.Flags().BoolVar(&readOnlyData, "read-only-data", false, "never write to the data dir (the key must already exist; give --stats-file and --control-socket writable paths)")
+	startCmd.Flags().BoolVar(&strictPerms, "strict-perms", false, "refuse to start if other users can access the data dir or key file, instead of warning")
+	startCmd.Flags().BoolVar(&fixPerms, "fix-perms", false, "remove permissions that let other users access the data dir or key file")
+	startCmd.Flags().DurationVar(&relayDialTimeout, "relay-dial-timeout", 0, "timeout for connecting a client's relay to its Psiphon server (default: 20s, scaled by network latency)")
+	startCmd.Flags().DurationVar(&brokerTimeout, "broker-request-timeout", 0, "how long to wait on a broker request, beyond the 2m the broker may hold an announcement, before failing and retrying (default: 20s for answers, 10s for announcements)")
+	startCmd.Flags().DurationVar(&announceBackoff, "announce-max-backoff", 0, "longest wait between broker announcements after a failure or a broker request to back off (default: 1m; broker tactics take precedence)")
+	startCmd.Flags().StringVar(&noticeURL, "operator-notice-url", "", "periodically fetch a signed operator notice from this URL and log it (requires --operator-notice-key)")
+	startCmd.Flags().StringVar(&noticeKey, "operator-notice-key", "", "base64 Ed25519 public key the operator notice must be signed with")
+	startCmd.Flags().StringVar(&fileMode, "file-mode", "", "octal permissions for the stats file and goroutine profiles, e.g. 0640 (default: 0644 less umask; the key file is always 0600)")
+	startCmd.Flags().StringVar(&influxAddr, "influx-addr", "", "also push metrics in InfluxDB line protocol: udp://host:port, an http(s) write URL, or file:///path (rewritten with the latest snapshot on each push)")
+	startCmd.Flags().DurationVar(&influxInterval, "influx-interval", 10*time.Second, "how often metrics are pushed to --influx-addr")
+	startCmd.Flags().StringVar(&remoteWriteURL, "remote-write-url", "", "also push metrics to a Prometheus remote write endpoint (user:password@ in the URL is sent as basic auth)")
+	startCmd.Flags().DurationVar(&remoteInterval, "remote-write-interval", 30*time.Second, "how often metrics are pushed to --remote-write-url")
+	startCmd.Flags().StringVar(&remoteTokenFile, "remote-write-token-file", "", "file holding a bearer token for --remote-write-url")
+	startCmd.Flags().StringVar(&dashboardAddr, "dashboard-addr", "", "serve a status web page, /healthz, /readyz and /metrics on this address, e.g. 127.0.0.1:8080")
+	startCmd.Flags().StringVar(&pprofAddr, "pprof-addr", "", "serve Go pprof profiles on this address, e.g. 127.0.0.1:6060 (exposes process internals; keep it local)")
+	startCmd.Flags().BoolVar(&leakWatchdog, "leak-watchdog", false, "warn and write a goroutine profile to the data dir if goroutines grow out of proportion to client sessions")
+	startCmd.Flags().StringVar(&dnsServer, "dns-server", "", "DNS server to prefer for broker name resolution (IP or IP:port, default: system resolver)")
 }
 
 func runStart(cmd *cobra.Command, args []string) error {
-	// Determine psiphon config source: flag > embedded > error
-	effectiveConfigPath := psiphonConfigPath
+	if logRateLimit < 0 {
+		return fmt.Errorf("log-rate-limit must not be negative")
+	}
+	logging.SetRateLimit(logRateLimit)
+
+	// Determine psiphon config source: flag > remote store > embedded > error
+	effectiveConfigPath := ""
 	useEmbedded := false
+	var remoteConfigData []byte
+
+	if len(psiphonConfigs) > 0 && psiphonConfigFrom != "" {
+		return fmt.Errorf("use only one of --psiphon-config and --psiphon-config-from")
+	}
 
-	if psiphonConfigPath != "" {
-		// User provided a config path - validate it exists
-		if _, err := os.Stat(psiphonConfigPath); os.IsNotExist(err) {
-			return fmt.Errorf("psiphon config file not found: %s", psiphonConfigPath)
+	if len(psiphonConfigs) > 0 {
+		// User provided config paths - validate they all exist, so a
+		// missing failover config is caught now rather than mid-outage
+		for _, path := range psiphonConfigs {
+			if _, err := os.Stat(path); os.IsNotExist(err) {
+				return fmt.Errorf("psiphon config file not found: %s", path)
+			}
+		}
+		effectiveConfigPath = psiphonConfigs[0]
+	} else if psiphonConfigFrom != "" {
+		data, err := fetchPsiphonConfig(cmd.Context())
+		if err != nil {
+			return err
 		}
+		remoteConfigData = data
 	} else if config.HasEmbeddedConfig() {
 		// No flag provided, but we have embedded config
 		useEmbedded = true
 	} else {
 		// No flag and no embedded config
-		return fmt.Errorf("psiphon config required: use --psiphon-config flag or build with embedded config")
+		return fmt.Errorf("psiphon config required: use --psiphon-config or --psiphon-config-from, or build with embedded config")
 	}
 
 	// Resolve stats file path - if relative, place in data dir
@@ -101,6 +211,18 @@ func runStart(cmd *cobra.Command, args []string) error {
 		resolvedStatsFile = filepath.Join(GetDataDir(), resolvedStatsFile)
 	}
 
+	// Resolve a metrics unix socket path - if relative, place in data dir
+	resolvedMetricsAddr := metricsAddr
+	if path, ok := metrics.UnixSocketPath(metricsAddr); ok && path != "" && !filepath.IsAbs(path) {
+		resolvedMetricsAddr = "unix:" + filepath.Join(GetDataDir(), path)
+	}
+
+	// Resolve control socket path - if relative, place in data dir
+	resolvedControlSocket := controlSocket
+	if resolvedControlSocket != "" && !filepath.IsAbs(resolvedControlSocket) {
+		resolvedControlSocket = filepath.Join(GetDataDir(), resolvedControlSocket)
+	}
+
 	maxClientsFromFlag := 0
 	if cmd.Flags().Changed("max-clients") {
 		if maxClients < 1 {
@@ -132,10 +254,46 @@ func runStart(cmd *cobra.Command, args []string) error {
 		idleRestartDuration = d
 	}
 
+	// Parse unhealthy-replace-after duration if provided
+	var unhealthyRestartDuration time.Duration
+	if unhealthyRestart != "" {
+		d, err := time.ParseDuration(unhealthyRestart)
+		if err != nil {
+			return fmt.Errorf("invalid unhealthy-replace-after duration %q: %w (use format like 10m, 30m)", unhealthyRestart, err)
+		}
+		if d < 5*time.Minute {
+			return fmt.Errorf("unhealthy-replace-after must be at least 5m")
+		}
+		unhealthyRestartDuration = d
+	}
+
+	// Failover happens when a config can't go live, so it needs a deadline
+	if len(psiphonConfigs) > 1 {
+		if unhealthyRestartDuration == 0 {
+			unhealthyRestartDuration = defaultFailoverTimeout
+		}
+		if configFailback < 5*time.Minute {
+			return fmt.Errorf("psiphon-config-failback must be at least 5m")
+		}
+		logging.Printf("[OK] Psiphon config failover: %d configs, switching if not live within %s\n",
+			len(psiphonConfigs), unhealthyRestartDuration)
+	}
+
+	if relayDialTimeout != 0 && relayDialTimeout < time.Second {
+		return fmt.Errorf("relay-dial-timeout must be at least 1s")
+	}
+	if brokerTimeout != 0 && brokerTimeout < time.Second {
+		return fmt.Errorf("broker-request-timeout must be at least 1s")
+	}
+	if announceBackoff != 0 && announceBackoff < time.Second {
+		return fmt.Errorf("announce-max-backoff must be at least 1s")
+	}
+
 	// Load or create configuration (auto-generates keys on first run)
-	cfg, err := config.LoadOrCreate(config.Options{
+	opts := config.Options{
 		DataDir:           GetDataDir(),
 		PsiphonConfigPath: effectiveConfigPath,
+		PsiphonConfigData: remoteConfigData,
 		UseEmbeddedConfig: useEmbedded,
 		MaxClients:        maxClientsFromFlag,
 		BandwidthMbps:     bandwidthFromFlag,
@@ -143,13 +301,91 @@ func runStart(cmd *cobra.Command, args []string) error {
 		Verbosity:         Verbosity(),
 		StatsFile:         resolvedStatsFile,
 		GeoEnabled:        geoEnabled,
-		MetricsAddr:       metricsAddr,
+		MetricsAddr:       resolvedMetricsAddr,
 		IdleRestart:       idleRestartDuration,
+		UnhealthyRestart:  unhealthyRestartDuration,
 		Compartment:       compartment,
-	})
+		ControlSocket:     resolvedControlSocket,
+		DNSServer:         dnsServer,
+		Ephemeral:         ephemeral,
+		IdentitySeed:      identitySeed,
+		MetricLabels:      metricLabels,
+		FingerprintMode:   fingerprintMode,
+		MemoryLimit:       memoryLimit,
+		MemoryPressure:    memoryPressure,
+		FDPressure:        fdPressure,
+		MinClientMbps:     minClientMbps,
+		Reregister:        reregister,
+		AnnounceJitter:    announceJitter,
+		AnnounceJitterSet: cmd.Flags().Changed("announce-jitter"),
+		ReadOnlyData:      readOnlyData,
+		StrictPerms:       strictPerms,
+		FixPerms:          fixPerms,
+		RelayDialTimeout:  relayDialTimeout,
+		AnnounceBackoff:   announceBackoff,
+		BrokerTimeout:     brokerTimeout,
+		MetricsPrivacy:    metricsPrivacy,
+		NoticeURL:         noticeURL,
+		NoticeKey:         noticeKey,
+		LeakWatchdog:      leakWatchdog,
+		Canary:            canary,
+		InstanceName:      instanceName,
+		FileMode:          fileMode,
+		InfluxAddr:        influxAddr,
+		InfluxInterval:    influxInterval,
+		RemoteWriteURL:    remoteWriteURL,
+		RemoteInterval:    remoteInterval,
+		RemoteTokenFile:   remoteTokenFile,
+		DashboardAddr:     dashboardAddr,
+	}
+	cfg, err := config.LoadOrCreate(opts)
 	if err != nil {
 		return fmt.Errorf("failed to load configuration: %w", err)
 	}
+	// cfg is replaced on reload, so remove whichever is current at exit
+	defer func() { removeTempDataDir(cfg) }()
+	// Reloads keep the ephemeral identity for the life of the process
+	if cfg.Ephemeral {
+		opts.EphemeralKey = cfg.KeyPair
+	}
+	logging.SetPrefix(cfg.InstanceName)
+
+	if cfg.ReadOnlyData {
+		logging.Printf("[OK] Read-only data directory %s: using the existing key, nothing is written there\n", opts.DataDir)
+	}
+	if cfg.Ephemeral {
+		logging.Println("[WARN] Ephemeral mode: using a throwaway identity that is discarded on exit.")
+		logging.Println("[WARN] Broker reputation will NOT accumulate; you may not receive client connections for some time.")
+	}
+	if cfg.IdentitySeeded {
+		logging.Println("[WARN] --identity-seed is set: new keys are derived from the seed, and anyone who has it can recreate them.")
+		logging.Println("[WARN] This is for test deployments only. NEVER use --identity-seed in production.")
+	}
+
+	if confirmStart && !assumeYes {
+		if !term.IsTerminal(int(os.Stdin.Fd())) {
+			logging.Println("[INFO] stdin is not a terminal, starting without confirmation")
+		} else if !confirmPlan(cfg) {
+			return fmt.Errorf("start not confirmed")
+		}
+	}
+
+	if pprofAddr != "" {
+		stopPprof, err := startPprofServer(pprofAddr)
+		if err != nil {
+			return err
+		}
+		defer stopPprof()
+	}
+
+	// Metrics outlive each service below, so that counters survive restarts
+	// and reloads. Reloads re-read the same flags, so cfg's exporters stay put.
+	serviceMetrics := conduit.NewMetrics(cfg)
+	stopMetrics, err := serviceMetrics.Serve(cfg)
+	if err != nil {
+		return err
+	}
+	defer stopMetrics()
 
 	// Setup context with cancellation
 	ctx, cancel := context.WithCancel(context.Background())
@@ -162,22 +398,109 @@ func runStart(cmd *cobra.Command, args []string) error {
 	go func() {
 		<-sigChan
 		logging.Println("Shutting down...")
+		if _, err := systemd.Notify("STOPPING=1"); err != nil {
+			logging.Printf("[WARN] %v\n", err)
+		}
 		cancel()
 	}()
 
-	// Run the service (with restart loop if idle-restart is enabled)
+	// Under WatchdogSec=, tell systemd the process is still running
+	go func() {
+		if err := systemd.Watchdog(ctx); err != nil {
+			logging.Printf("[WARN] systemd watchdog stopped: %v\n", err)
+		}
+	}()
+
+	// SIGHUP re-reads the psiphon config and restarts the service with it
+	reloadChan := make(chan os.Signal, 1)
+	signal.Notify(reloadChan, syscall.SIGHUP)
+
+	// SIGQUIT writes a diagnostics bundle instead of exiting with a stack dump
+	diagChan := make(chan os.Signal, 1)
+	signal.Notify(diagChan, syscall.SIGQUIT)
+
+	// Run the service (with restart loop for idle-restart and reloads)
+	replacingUnhealthy := false
+	failingOver := false
+	configIndex := 0
+	configEpoch := 1 // Bumped each time a different config is applied
 	for {
 		// Create conduit service
-		service, err := conduit.New(cfg)
+		service, err := conduit.New(cfg, serviceMetrics)
 		if err != nil {
 			return fmt.Errorf("failed to create conduit service: %w", err)
 		}
+		// The control socket reload command takes the same path as SIGHUP
+		service.SetReloadHandler(func() error {
+			select {
+			case reloadChan <- syscall.SIGHUP:
+				return nil
+			default:
+				return errors.New("a reload is already pending")
+			}
+		})
+		if replacingUnhealthy {
+			service.RecordUnhealthyRestart()
+			replacingUnhealthy = false
+		}
+		if failingOver {
+			service.RecordConfigFailover()
+			failingOver = false
+		}
+		service.SetConfigEpoch(configEpoch)
+
+		// Run the service, stopping it early if a reload is accepted
+		runCtx, cancelRun := context.WithCancel(ctx)
+		reloaded := make(chan *config.Config, 1)
+		go watchReload(runCtx, cancelRun, reloadChan, opts, service, reloaded)
+		go watchDiagnostics(runCtx, diagChan, service)
+
+		// On a failover config, stop after a while to give the first one
+		// another chance
+		var failbackTimer *time.Timer
+		if configIndex > 0 {
+			failbackTimer = time.AfterFunc(configFailback, cancelRun)
+		}
+
+		err = service.Run(runCtx)
+		failingBack := failbackTimer != nil && !failbackTimer.Stop()
+		cancelRun()
+
+		select {
+		case newCfg := <-reloaded:
+			if ctx.Err() == nil {
+				removeTempDataDir(cfg)
+				cfg = newCfg
+				configEpoch++
+				logging.Println("[OK] Configuration reloaded, restarting service")
+				continue
+			}
+			removeTempDataDir(newCfg)
+		default:
+		}
 
-		// Run the service
-		err = service.Run(ctx)
+		if failingBack && ctx.Err() == nil {
+			logging.Printf("[INFO] Retrying primary psiphon config %s\n", psiphonConfigs[0])
+			if switchPsiphonConfig(&opts, &cfg, psiphonConfigs[0]) {
+				configIndex = 0
+				configEpoch++
+			}
+			continue
+		}
 
-		// Check if we should restart due to idle timeout
-		if errors.Is(err, conduit.ErrIdleRestart) {
+		// Check if we should restart due to idle timeout or poor health
+		if errors.Is(err, conduit.ErrIdleRestart) || errors.Is(err, conduit.ErrUnhealthyRestart) {
+			replacingUnhealthy = errors.Is(err, conduit.ErrUnhealthyRestart)
+			if replacingUnhealthy && len(psiphonConfigs) > 1 {
+				next := (configIndex + 1) % len(psiphonConfigs)
+				logging.Printf("[WARN] Psiphon config %s did not go live, failing over to %s\n",
+					psiphonConfigs[configIndex], psiphonConfigs[next])
+				if switchPsiphonConfig(&opts, &cfg, psiphonConfigs[next]) {
+					configIndex = next
+					configEpoch++
+					failingOver = true
+				}
+			}
 			// Brief pause before restarting
 			select {
 			case <-ctx.Done():
@@ -199,3 +522,159 @@ func runStart(cmd *cobra.Command, args []string) error {
 	logging.Println("Stopped.")
 	return nil
 }
+
+// watchDiagnostics writes a diagnostics bundle on each SIGQUIT while the
+// service runs
+func watchDiagnostics(ctx context.Context, diagChan <-chan os.Signal, service *conduit.Service) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-diagChan:
+			path, err := service.WriteDiagnostics()
+			if err != nil {
+				logging.Printf("[WARN] Failed to write diagnostics: %v\n", err)
+				continue
+			}
+			logging.Printf("[INFO] Diagnostics written to %s\n", path)
+		}
+	}
+}
+
+// watchReload waits for SIGHUP while the service runs. A reload is only
+// applied (by cancelling the run and handing over the new config) once the
+// new config has loaded cleanly; otherwise the running config is kept.
+func watchReload(ctx context.Context, cancelRun context.CancelFunc, reloadChan <-chan os.Signal, opts config.Options, service *conduit.Service, reloaded chan<- *config.Config) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-reloadChan:
+			logging.Println("Reloading configuration...")
+			newCfg, err := reloadConfig(opts)
+			if err != nil {
+				logging.Printf("[ERROR] Config reload failed, keeping current config: %v\n", err)
+				service.RecordConfigReloadFailure()
+				continue
+			}
+			reloaded <- newCfg
+			cancelRun()
+			return
+		}
+	}
+}
+
+// confirmPlan prints the resolved configuration and asks the operator to type
+// yes before starting
+func confirmPlan(cfg *config.Config) bool {
+	configSource := cfg.PsiphonConfigPath
+	if psiphonConfigFrom != "" {
+		configSource = psiphonConfigFrom
+	} else if configSource == "" {
+		configSource = "embedded"
+	}
+	bandwidth := "unlimited"
+	if cfg.BandwidthBytesPerSecond > 0 {
+		bandwidth = fmt.Sprintf("%.0f Mbps", float64(cfg.BandwidthBytesPerSecond)*8/1000/1000)
+	}
+	metrics := "disabled"
+	if cfg.MetricsAddr != "" {
+		metrics = cfg.MetricsAddr
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintf(writer, "Psiphon config:\t%s\n", configSource)
+	_, _ = fmt.Fprintf(writer, "Data dir:\t%s\n", cfg.DataDir)
+	_, _ = fmt.Fprintf(writer, "Max clients:\t%d\n", cfg.MaxClients)
+	_, _ = fmt.Fprintf(writer, "Bandwidth:\t%s\n", bandwidth)
+	_, _ = fmt.Fprintf(writer, "Metrics:\t%s\n", metrics)
+	if cfg.IdleRestart > 0 {
+		_, _ = fmt.Fprintf(writer, "Idle restart:\t%s\n", cfg.IdleRestart)
+	}
+	if cfg.CompartmentID != "" {
+		_, _ = fmt.Fprintf(writer, "Compartment:\tpersonal\n")
+	}
+	if cfg.Ephemeral {
+		_, _ = fmt.Fprintf(writer, "Identity:\tephemeral\n")
+	}
+	if cfg.IdentitySeeded {
+		_, _ = fmt.Fprintf(writer, "Identity seed:\tset (testing only)\n")
+	}
+	_ = writer.Flush()
+
+	fmt.Print("\nType 'yes' to start: ")
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false
+	}
+	return strings.TrimSpace(answer) == "yes"
+}
+
+// fetchPsiphonConfig fetches the psiphon config from --psiphon-config-from and
+// validates it, since a bad secret would otherwise only fail at the broker
+func fetchPsiphonConfig(ctx context.Context) ([]byte, error) {
+	data, err := remoteconfig.Fetch(ctx, psiphonConfigFrom)
+	if err != nil {
+		return nil, err
+	}
+	if err := config.ValidatePsiphonConfig(data); err != nil {
+		return nil, fmt.Errorf("config from %s is not usable: %w", psiphonConfigFrom, err)
+	}
+	return data, nil
+}
+
+// removeTempDataDir deletes the temporary data directory of an ephemeral or
+// read-only config
+func removeTempDataDir(cfg *config.Config) {
+	if cfg == nil || !cfg.TempDataDir {
+		return
+	}
+	if err := os.RemoveAll(cfg.DataDir); err != nil {
+		logging.Printf("[WARN] Failed to remove temporary data directory %s: %v\n", cfg.DataDir, err)
+	}
+}
+
+// defaultFailoverTimeout is how long a psiphon config gets to go live before
+// failing over, when --unhealthy-replace-after is not given
+const defaultFailoverTimeout = 10 * time.Minute
+
+// switchPsiphonConfig replaces cfg with one loaded from the psiphon config at
+// path, keeping the current config if the new one fails to load
+func switchPsiphonConfig(opts *config.Options, cfg **config.Config, path string) bool {
+	newOpts := *opts
+	newOpts.PsiphonConfigPath = path
+	newCfg, err := reloadConfig(newOpts)
+	if err != nil {
+		logging.Printf("[ERROR] Failed to load psiphon config %s, keeping current config: %v\n", path, err)
+		return false
+	}
+
+	removeTempDataDir(*cfg)
+	*opts = newOpts
+	*cfg = newCfg
+	return true
+}
+
+// reloadConfig re-reads the psiphon config and re-resolves the configuration
+// with the original flags
+func reloadConfig(opts config.Options) (*config.Config, error) {
+	if psiphonConfigFrom != "" {
+		// Fetched configs are always validated
+		data, err := fetchPsiphonConfig(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		opts.PsiphonConfigData = data
+	} else if checkOnReload && opts.PsiphonConfigPath != "" {
+		data, err := os.ReadFile(opts.PsiphonConfigPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read psiphon config file: %w", err)
+		}
+		if err := config.ValidatePsiphonConfig(data); err != nil {
+			return nil, err
+		}
+	}
+
+	// LoadOrCreate applies the same parsing and range checks as startup
+	return config.LoadOrCreate(opts)
+}
@@ -0,0 +1,233 @@
+/*
+ * Copyright (c) 2026, Psiphon Inc.
+ * All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Psiphon-Inc/conduit/cli/internal/conduit"
+	"github.com/Psiphon-Inc/conduit/cli/internal/control"
+	"github.com/spf13/cobra"
+)
+
+var (
+	statsDiffJSON           bool
+	statsResetControlSocket string
+)
+
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Work with stats files written by --stats-file and the running service's stats",
+}
+
+var statsDiffCmd = &cobra.Command{
+	Use:   "diff <before.json> <after.json>",
+	Short: "Show what changed between two stats snapshots",
+	Long: `Show the traffic, clients and uptime accumulated between two stats files
+written by 'conduit start --stats-file'.
+
+Totals reset when the service restarts. If the second snapshot comes from a
+restarted service, the deltas cover only the time since that restart.`,
+	Args: cobra.ExactArgs(2),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"json"}, cobra.ShellCompDirectiveFilterFileExt
+	},
+	RunE: runStatsDiff,
+}
+
+var statsResetPeakCmd = &cobra.Command{
+	Use:   "reset-peak",
+	Short: "Reset the peak connected clients of the running service",
+	Long: `Start a new peak clients window on the running service (start Conduit with
+--control-socket) and print the peak of the window that ended.
+
+The peak since the service started (peakClients in the stats, since="start"
+in conduit_peak_clients) is not affected; only the peak since the last
+reset (peakClientsSinceReset, since="reset") starts over from the clients
+connected now.`,
+	Args: cobra.NoArgs,
+	RunE: runStatsResetPeak,
+}
+
+func init() {
+	rootCmd.AddCommand(statsCmd)
+	statsCmd.AddCommand(statsDiffCmd)
+	statsCmd.AddCommand(statsResetPeakCmd)
+
+	statsDiffCmd.Flags().BoolVar(&statsDiffJSON, "json", false, "print the deltas as JSON")
+	statsResetPeakCmd.Flags().StringVar(&statsResetControlSocket, "control-socket", "conduit.sock", "control socket of the running service (relative paths are in the data dir)")
+}
+
+// statsDelta is the change between two stats snapshots
+type statsDelta struct {
+	IntervalSeconds int64 `json:"intervalSeconds"`
+	UptimeSeconds   int64 `json:"uptimeSeconds"`
+	BytesUp         int64 `json:"bytesUp"`
+	BytesDown       int64 `json:"bytesDown"`
+	ClientsServed   *int  `json:"clientsServed,omitempty"` // Only known with --geo
+	ConnectedBefore int   `json:"connectedBefore"`
+	ConnectedAfter  int   `json:"connectedAfter"`
+	Restarted       bool  `json:"restarted"` // Totals reset between the snapshots
+}
+
+func runStatsDiff(cmd *cobra.Command, args []string) error {
+	before, err := readStatsFile(args[0])
+	if err != nil {
+		return err
+	}
+	after, err := readStatsFile(args[1])
+	if err != nil {
+		return err
+	}
+
+	if err := checkSnapshotOrder(args[0], args[1], before, after); err != nil {
+		return err
+	}
+	delta := diffStats(before, after)
+
+	if statsDiffJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(delta)
+	}
+
+	if delta.Restarted {
+		fmt.Println("The service restarted between the snapshots; deltas cover the time since the restart.")
+		fmt.Println()
+	}
+	clientsServed := "unknown (start with --geo to count)"
+	if delta.ClientsServed != nil {
+		clientsServed = fmt.Sprintf("%d", *delta.ClientsServed)
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintf(writer, "Interval:\t%s\n", formatAge(time.Duration(delta.IntervalSeconds)*time.Second))
+	_, _ = fmt.Fprintf(writer, "Uptime:\t+%s\n", formatAge(time.Duration(delta.UptimeSeconds)*time.Second))
+	_, _ = fmt.Fprintf(writer, "Uploaded:\t+%s\n", conduit.FormatBytes(delta.BytesUp))
+	_, _ = fmt.Fprintf(writer, "Downloaded:\t+%s\n", conduit.FormatBytes(delta.BytesDown))
+	_, _ = fmt.Fprintf(writer, "Clients served:\t%s\n", clientsServed)
+	_, _ = fmt.Fprintf(writer, "Connected clients:\t%d -> %d\n", delta.ConnectedBefore, delta.ConnectedAfter)
+	return writer.Flush()
+}
+
+func runStatsResetPeak(cmd *cobra.Command, args []string) error {
+	line, err := control.Query(resolveDataPath(statsResetControlSocket), "reset-peak", 5*time.Second)
+	if err != nil {
+		return err
+	}
+	var response struct {
+		Error        string `json:"error"`
+		PreviousPeak int    `json:"previousPeak"`
+	}
+	if err := json.Unmarshal(line, &response); err != nil {
+		return fmt.Errorf("invalid reset-peak response: %w", err)
+	}
+	if response.Error != "" {
+		return fmt.Errorf("reset-peak refused: %s", response.Error)
+	}
+	fmt.Printf("Peak clients reset (previous peak: %d)\n", response.PreviousPeak)
+	return nil
+}
+
+// readStatsFile reads a stats snapshot written by --stats-file
+func readStatsFile(path string) (conduit.StatsJSON, error) {
+	var stats conduit.StatsJSON
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return stats, err
+	}
+	if err := json.Unmarshal(data, &stats); err != nil {
+		return stats, fmt.Errorf("invalid stats file %s: %w", path, err)
+	}
+	if err := stats.CheckSchema(); err != nil {
+		return stats, fmt.Errorf("%s: %w", path, err)
+	}
+	return stats, nil
+}
+
+// checkSnapshotOrder rejects snapshots given newest first. The timestamps are
+// compared as times, since they may be written in different time zones.
+func checkSnapshotOrder(beforePath, afterPath string, before, after conduit.StatsJSON) error {
+	beforeTime, err := time.Parse(time.RFC3339, before.Timestamp)
+	if err != nil {
+		return fmt.Errorf("%s: invalid timestamp %q", beforePath, before.Timestamp)
+	}
+	afterTime, err := time.Parse(time.RFC3339, after.Timestamp)
+	if err != nil {
+		return fmt.Errorf("%s: invalid timestamp %q", afterPath, after.Timestamp)
+	}
+	if beforeTime.After(afterTime) {
+		return fmt.Errorf("%s is newer than %s; give the older snapshot first", beforePath, afterPath)
+	}
+	return nil
+}
+
+// diffStats computes what accumulated from before to after. A total that went
+// down means the service restarted, so after's totals are the delta.
+func diffStats(before, after conduit.StatsJSON) statsDelta {
+	delta := statsDelta{
+		ConnectedBefore: before.ConnectedClients,
+		ConnectedAfter:  after.ConnectedClients,
+		Restarted: after.UptimeSeconds < before.UptimeSeconds ||
+			after.TotalBytesUp < before.TotalBytesUp ||
+			after.TotalBytesDown < before.TotalBytesDown,
+	}
+
+	beforeTime, errBefore := time.Parse(time.RFC3339, before.Timestamp)
+	afterTime, errAfter := time.Parse(time.RFC3339, after.Timestamp)
+	if errBefore == nil && errAfter == nil {
+		delta.IntervalSeconds = int64(afterTime.Sub(beforeTime).Seconds())
+	}
+
+	if delta.Restarted {
+		delta.UptimeSeconds = after.UptimeSeconds
+		delta.BytesUp = after.TotalBytesUp
+		delta.BytesDown = after.TotalBytesDown
+	} else {
+		delta.UptimeSeconds = after.UptimeSeconds - before.UptimeSeconds
+		delta.BytesUp = after.TotalBytesUp - before.TotalBytesUp
+		delta.BytesDown = after.TotalBytesDown - before.TotalBytesDown
+	}
+
+	// Clients served is only tracked per country by geo. If geo was only
+	// enabled after the first snapshot, its totals cover the whole run
+	// unless the service restarted in between
+	if after.Geo != nil && (before.Geo != nil || delta.Restarted) {
+		served := geoClientsTotal(after)
+		if !delta.Restarted {
+			served -= geoClientsTotal(before)
+		}
+		delta.ClientsServed = &served
+	}
+
+	return delta
+}
+
+func geoClientsTotal(stats conduit.StatsJSON) int {
+	total := 0
+	for _, r := range stats.Geo {
+		total += r.CountTotal
+	}
+	return total
+}
@@ -0,0 +1,120 @@
+/*
+ * Copyright (c) 2026, Psiphon Inc.
+ * All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/Psiphon-Inc/conduit/cli/internal/conduit"
+	"github.com/Psiphon-Inc/conduit/cli/internal/geo"
+)
+
+func TestDiffStats(t *testing.T) {
+	before := conduit.StatsJSON{
+		ConnectedClients: 4,
+		TotalBytesUp:     1000,
+		TotalBytesDown:   5000,
+		UptimeSeconds:    600,
+		Geo:              []geo.Result{{Code: "IR", CountTotal: 7}},
+		Timestamp:        "2026-01-01T10:00:00Z",
+	}
+	after := conduit.StatsJSON{
+		ConnectedClients: 2,
+		TotalBytesUp:     1500,
+		TotalBytesDown:   9000,
+		UptimeSeconds:    900,
+		Geo:              []geo.Result{{Code: "IR", CountTotal: 9}, {Code: "DE", CountTotal: 1}},
+		Timestamp:        "2026-01-01T10:05:00Z",
+	}
+
+	delta := diffStats(before, after)
+	if delta.Restarted || delta.IntervalSeconds != 300 || delta.UptimeSeconds != 300 ||
+		delta.BytesUp != 500 || delta.BytesDown != 4000 ||
+		delta.ConnectedBefore != 4 || delta.ConnectedAfter != 2 {
+		t.Errorf("unexpected delta: %+v", delta)
+	}
+	if delta.ClientsServed == nil || *delta.ClientsServed != 3 {
+		t.Errorf("ClientsServed = %v, expected 3", delta.ClientsServed)
+	}
+
+	// Totals that went down mean the service restarted, so after's totals
+	// are the delta
+	after.UptimeSeconds = 120
+	after.TotalBytesUp = 200
+	delta = diffStats(before, after)
+	if !delta.Restarted || delta.UptimeSeconds != 120 || delta.BytesUp != 200 || delta.BytesDown != 9000 {
+		t.Errorf("unexpected delta after a restart: %+v", delta)
+	}
+	if delta.ClientsServed == nil || *delta.ClientsServed != 10 {
+		t.Errorf("ClientsServed after a restart = %v, expected 10", delta.ClientsServed)
+	}
+
+	// Geo enabled by the restart counts every client since then
+	beforeGeo := before.Geo
+	before.Geo = nil
+	delta = diffStats(before, after)
+	if delta.ClientsServed == nil || *delta.ClientsServed != 10 {
+		t.Errorf("ClientsServed with geo enabled at a restart = %v, expected 10", delta.ClientsServed)
+	}
+
+	// Geo enabled without a restart has no starting totals to subtract
+	after.UptimeSeconds = 900
+	after.TotalBytesUp = 1500
+	if delta := diffStats(before, after); delta.ClientsServed != nil {
+		t.Errorf("ClientsServed = %d with geo only in the second snapshot, expected unknown", *delta.ClientsServed)
+	}
+
+	// Without geo the clients served are unknown
+	before.Geo, after.Geo = beforeGeo, nil
+	if delta := diffStats(before, after); delta.ClientsServed != nil {
+		t.Errorf("ClientsServed = %d without geo in the second snapshot, expected unknown", *delta.ClientsServed)
+	}
+	before.Geo = nil
+	if delta := diffStats(before, after); delta.ClientsServed != nil {
+		t.Errorf("ClientsServed = %d without geo, expected unknown", *delta.ClientsServed)
+	}
+}
+
+func TestCheckSnapshotOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		before  string
+		after   string
+		wantErr bool
+	}{
+		{name: "in_order", before: "2026-01-01T10:00:00Z", after: "2026-01-01T10:05:00Z"},
+		{name: "same_time", before: "2026-01-01T10:00:00Z", after: "2026-01-01T10:00:00Z"},
+		{name: "reversed", before: "2026-01-01T10:05:00Z", after: "2026-01-01T10:00:00Z", wantErr: true},
+		// 08:00Z is earlier, though the string sorts after 09:30Z
+		{name: "time_zones", before: "2026-01-01T10:00:00+02:00", after: "2026-01-01T09:30:00Z"},
+		{name: "time_zones_reversed", before: "2026-01-01T09:30:00Z", after: "2026-01-01T10:00:00+02:00", wantErr: true},
+		{name: "invalid", before: "yesterday", after: "2026-01-01T10:00:00Z", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := checkSnapshotOrder("before.json", "after.json",
+				conduit.StatsJSON{Timestamp: test.before}, conduit.StatsJSON{Timestamp: test.after})
+			if (err != nil) != test.wantErr {
+				t.Fatalf("checkSnapshotOrder(%s, %s) = %v, expected error: %v", test.before, test.after, err, test.wantErr)
+			}
+		})
+	}
+}
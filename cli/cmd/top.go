@@ -0,0 +1,400 @@
+/*
+ * Copyright (c) 2026, Psiphon Inc.
+ * All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Psiphon-Inc/conduit/cli/internal/conduit"
+	"github.com/Psiphon-Inc/conduit/cli/internal/control"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var (
+	topControlSocket string
+	topStatsFile     string
+)
+
+// topRefreshInterval is how often the live view polls for new stats
+const topRefreshInterval = time.Second
+
+var topCmd = &cobra.Command{
+	Use:   "top",
+	Short: "Show a live view of a running Conduit",
+	Long: `Show a live, htop-style view of a running Conduit service.
+
+Stats are read once a second from the control socket (start Conduit with
+--control-socket), or from a stats file with --stats-file.
+
+Keys: q to quit, enter or d for instance details, esc or b to go back.`,
+	Args: cobra.NoArgs,
+	RunE: runTop,
+}
+
+func init() {
+	rootCmd.AddCommand(topCmd)
+
+	topCmd.Flags().StringVar(&topControlSocket, "control-socket", "conduit.sock", "control socket of the running service (relative paths are in the data dir)")
+	topCmd.Flags().StringVarP(&topStatsFile, "stats-file", "s", "", "read stats from this JSON file instead of the control socket")
+	_ = topCmd.MarkFlagFilename("stats-file", "json")
+}
+
+// topSample is one poll of the service stats
+type topSample struct {
+	status conduit.StatusJSON
+	at     time.Time
+}
+
+// topState holds what the live view needs between refreshes
+type topState struct {
+	source   string
+	detail   bool
+	current  *topSample
+	previous *topSample
+	err      error
+
+	// Highest observed throughput, used to scale the bar when the
+	// bandwidth is unlimited
+	peakRate float64
+}
+
+func runTop(cmd *cobra.Command, args []string) error {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) || !term.IsTerminal(int(os.Stdout.Fd())) {
+		return fmt.Errorf("top requires an interactive terminal")
+	}
+
+	fetch, source := topFetcher()
+
+	oldState, err := term.MakeRaw(fd)
+	if err != nil {
+		return fmt.Errorf("failed to set terminal mode: %w", err)
+	}
+	defer func() { _ = term.Restore(fd, oldState) }()
+
+	// Use the alternate screen so the shell is left as it was on exit
+	fmt.Print("\033[?1049h\033[?25l")
+	defer fmt.Print("\033[?25h\033[?1049l")
+
+	keys, stopKeys := readKeys(fd)
+	defer stopKeys()
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(sigChan)
+
+	ticker := time.NewTicker(topRefreshInterval)
+	defer ticker.Stop()
+
+	state := &topState{source: source}
+	state.refresh(fetch)
+	state.draw()
+
+	for {
+		select {
+		case <-sigChan:
+			return nil
+		case key, ok := <-keys:
+			if !ok {
+				return nil
+			}
+			switch key {
+			case 'q', 'Q', 3: // 3 is Ctrl-C in raw mode
+				return nil
+			case '\r', '\n', 'd', 'D':
+				state.detail = true
+			case 27, 'b', 'B': // 27 is Esc
+				state.detail = false
+			}
+			state.draw()
+		case <-ticker.C:
+			state.refresh(fetch)
+			state.draw()
+		}
+	}
+}
+
+// readKeysBlocking forwards key presses read from r until stop is called or
+// the read fails. stop doesn't wait for a read in progress.
+func readKeysBlocking(r io.Reader) (keys <-chan byte, stop func()) {
+	ch := make(chan byte)
+	done := make(chan struct{})
+	go forwardKeys(r, ch, done)
+	return ch, func() { close(done) }
+}
+
+// forwardKeys sends each byte read from r to keys until done is closed or
+// the read fails, then closes keys
+func forwardKeys(r io.Reader, keys chan<- byte, done <-chan struct{}) {
+	defer close(keys)
+	buf := make([]byte, 16)
+	for {
+		n, err := r.Read(buf)
+		if err != nil {
+			return
+		}
+		for _, b := range buf[:n] {
+			select {
+			case keys <- b:
+			case <-done:
+				return
+			}
+		}
+	}
+}
+
+// topFetcher returns a function that reads the current status, and a
+// description of where it reads it from
+func topFetcher() (func() (conduit.StatusJSON, error), string) {
+	if topStatsFile != "" {
+		path := resolveDataPath(topStatsFile)
+		return func() (conduit.StatusJSON, error) {
+			var status conduit.StatusJSON
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return status, err
+			}
+			if err := json.Unmarshal(data, &status); err != nil {
+				return status, fmt.Errorf("invalid stats file: %w", err)
+			}
+			return status, status.CheckSchema()
+		}, path
+	}
+
+	path := resolveDataPath(topControlSocket)
+	return func() (conduit.StatusJSON, error) {
+		var status conduit.StatusJSON
+		line, err := control.Query(path, "status", topRefreshInterval)
+		if err != nil {
+			return status, err
+		}
+		if err := json.Unmarshal(line, &status); err != nil {
+			return status, fmt.Errorf("invalid status response: %w", err)
+		}
+		return status, status.CheckSchema()
+	}, path
+}
+
+// resolveDataPath places relative paths in the data dir
+func resolveDataPath(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(GetDataDir(), path)
+}
+
+func (t *topState) refresh(fetch func() (conduit.StatusJSON, error)) {
+	status, err := fetch()
+	t.err = err
+	if err != nil {
+		return
+	}
+
+	sample := &topSample{status: status, at: time.Now()}
+	t.previous, t.current = t.current, sample
+
+	up, down := t.rates()
+	if up+down > t.peakRate {
+		t.peakRate = up + down
+	}
+}
+
+// rates returns the upload and download rates in bytes per second between
+// the last two samples
+func (t *topState) rates() (float64, float64) {
+	if t.current == nil || t.previous == nil {
+		return 0, 0
+	}
+	elapsed := t.current.at.Sub(t.previous.at).Seconds()
+	if elapsed <= 0 {
+		return 0, 0
+	}
+	up := float64(t.current.status.TotalBytesUp-t.previous.status.TotalBytesUp) / elapsed
+	down := float64(t.current.status.TotalBytesDown-t.previous.status.TotalBytesDown) / elapsed
+	// Counters go back to zero when the service restarts
+	if up < 0 || down < 0 {
+		return 0, 0
+	}
+	return up, down
+}
+
+func (t *topState) draw() {
+	width, _, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || width < 40 {
+		width = 80
+	}
+
+	var lines []string
+	if t.detail {
+		lines = t.detailLines(width)
+	} else {
+		lines = t.overviewLines(width)
+	}
+
+	// Raw mode needs explicit carriage returns
+	fmt.Print("\033[H\033[2J" + strings.Join(lines, "\r\n") + "\r\n")
+}
+
+func (t *topState) header(width int, keys string) []string {
+	title := fmt.Sprintf("Conduit top - %s", time.Now().Format("15:04:05"))
+	lines := []string{title, keys, t.source, strings.Repeat("-", width)}
+	if t.err != nil {
+		lines = append(lines, fmt.Sprintf("Not reachable: %v", t.err), "")
+	}
+	return lines
+}
+
+func (t *topState) overviewLines(width int) []string {
+	lines := t.header(width, "q quit | enter details")
+	if t.current == nil {
+		return append(lines, "Waiting for stats...")
+	}
+
+	status := t.current.status
+	up, down := t.rates()
+	barWidth := (width - 40) / 2
+	if barWidth < 10 {
+		barWidth = 10
+	}
+
+	maxClients := float64(status.MaxClients)
+	clientsLabel := fmt.Sprintf("%d/%d", status.ConnectedClients, status.MaxClients)
+	if status.MaxClients == 0 {
+		maxClients = float64(status.ConnectedClients)
+		clientsLabel = fmt.Sprintf("%d", status.ConnectedClients)
+	}
+
+	maxRate := float64(status.BandwidthBytesPerSecond)
+	if maxRate <= 0 {
+		maxRate = t.peakRate
+	}
+
+	lines = append(lines,
+		fmt.Sprintf("Broker:   %s", topBrokerState(status)),
+		"",
+		fmt.Sprintf("%-10s %-12s %s", "INSTANCE", "STATE", "CLIENTS / THROUGHPUT"),
+		fmt.Sprintf("%-10s %-12s %s %s", "conduit", topInstanceState(status),
+			topBar(float64(status.ConnectedClients), maxClients, barWidth), clientsLabel),
+		fmt.Sprintf("%-10s %-12s %s %s/s", "", "",
+			topBar(up+down, maxRate, barWidth), conduit.FormatBytes(int64(up+down))),
+		"",
+		fmt.Sprintf("Totals:   %d connected, %d connecting | up %s/s, down %s/s | %s up, %s down",
+			status.ConnectedClients, status.ConnectingClients,
+			conduit.FormatBytes(int64(up)), conduit.FormatBytes(int64(down)),
+			conduit.FormatBytes(status.TotalBytesUp), conduit.FormatBytes(status.TotalBytesDown)),
+	)
+	return lines
+}
+
+func (t *topState) detailLines(width int) []string {
+	lines := t.header(width, "q quit | esc back")
+	if t.current == nil {
+		return append(lines, "Waiting for stats...")
+	}
+
+	status := t.current.status
+	up, down := t.rates()
+
+	// Stats files don't carry the limits
+	maxClients := "unknown"
+	bandwidth := "unknown"
+	if status.MaxClients > 0 {
+		maxClients = fmt.Sprintf("%d", status.MaxClients)
+		bandwidth = "unlimited"
+		if status.BandwidthBytesPerSecond > 0 {
+			bandwidth = fmt.Sprintf("%s/s", conduit.FormatBytes(int64(status.BandwidthBytesPerSecond)))
+		}
+	}
+
+	lines = append(lines,
+		"Instance:           conduit",
+		fmt.Sprintf("State:              %s", topInstanceState(status)),
+		fmt.Sprintf("Broker:             %s", topBrokerState(status)),
+		fmt.Sprintf("Connected clients:  %d (max %s, peak %d)", status.ConnectedClients, maxClients, status.PeakSinceReset),
+		fmt.Sprintf("Connecting clients: %d", status.ConnectingClients),
+		fmt.Sprintf("Bandwidth limit:    %s", bandwidth),
+		fmt.Sprintf("Upload:             %s/s (%s total)", conduit.FormatBytes(int64(up)), conduit.FormatBytes(status.TotalBytesUp)),
+		fmt.Sprintf("Download:           %s/s (%s total)", conduit.FormatBytes(int64(down)), conduit.FormatBytes(status.TotalBytesDown)),
+		fmt.Sprintf("Uptime:             %s", time.Duration(status.UptimeSeconds)*time.Second),
+		fmt.Sprintf("Idle:               %s", time.Duration(status.IdleSeconds)*time.Second),
+		fmt.Sprintf("Last update:        %s", status.Timestamp),
+	)
+
+	if len(status.Geo) > 0 {
+		geoResults := append(status.Geo[:0:0], status.Geo...)
+		sort.Slice(geoResults, func(i, j int) bool {
+			return geoResults[i].Count > geoResults[j].Count
+		})
+		lines = append(lines, "", fmt.Sprintf("%-24s %8s %8s %10s %10s", "COUNTRY", "CLIENTS", "TOTAL", "UP", "DOWN"))
+		for _, r := range geoResults {
+			lines = append(lines, fmt.Sprintf("%-24s %8d %8d %10s %10s",
+				r.Country, r.Count, r.CountTotal, conduit.FormatBytes(r.BytesUp), conduit.FormatBytes(r.BytesDown)))
+		}
+	}
+	return lines
+}
+
+// topInstanceState summarizes whether the proxy is serving
+func topInstanceState(status conduit.StatusJSON) string {
+	switch {
+	case status.ConnectedClients > 0:
+		return "serving"
+	case status.IsLive:
+		return "live"
+	default:
+		return "starting"
+	}
+}
+
+// topBrokerState summarizes the connection to the Psiphon broker
+func topBrokerState(status conduit.StatusJSON) string {
+	if !status.IsLive {
+		return "connecting"
+	}
+	if status.Announcing > 0 {
+		return fmt.Sprintf("connected, %d announcement(s) waiting for clients", status.Announcing)
+	}
+	return "connected"
+}
+
+// topBar renders value as a fraction of limit in a fixed-width bar
+func topBar(value, limit float64, width int) string {
+	filled := 0
+	if limit > 0 {
+		filled = int(value / limit * float64(width))
+	}
+	if filled > width {
+		filled = width
+	}
+	if filled < 0 {
+		filled = 0
+	}
+	return "[" + strings.Repeat("#", filled) + strings.Repeat(".", width-filled) + "]"
+}
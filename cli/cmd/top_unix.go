@@ -0,0 +1,66 @@
+//go:build unix
+
+/*
+ * Copyright (c) 2026, Psiphon Inc.
+ * All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// readKeys forwards key presses on the terminal fd until stop is called. The
+// reads go through a non-blocking duplicate of fd, so that stop can interrupt
+// one that is waiting for a key.
+func readKeys(fd int) (keys <-chan byte, stop func()) {
+	dup, err := syscall.Dup(fd)
+	if err != nil {
+		return readKeysBlocking(os.Stdin)
+	}
+	// The flag is shared with fd, so it is cleared again on stop
+	if err := syscall.SetNonblock(dup, true); err != nil {
+		_ = syscall.Close(dup)
+		return readKeysBlocking(os.Stdin)
+	}
+	f := os.NewFile(uintptr(dup), "stdin")
+	if err := f.SetReadDeadline(time.Time{}); err != nil {
+		// Not pollable, so a deadline couldn't interrupt the read
+		_ = f.Close()
+		_ = syscall.SetNonblock(fd, false)
+		return readKeysBlocking(os.Stdin)
+	}
+
+	ch := make(chan byte)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		forwardKeys(f, ch, done)
+	}()
+
+	return ch, func() {
+		close(done)
+		_ = f.SetReadDeadline(time.Now())
+		<-stopped
+		_ = f.Close()
+		_ = syscall.SetNonblock(fd, false)
+	}
+}
@@ -0,0 +1,61 @@
+//go:build unix
+
+/*
+ * Copyright (c) 2026, Psiphon Inc.
+ * All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+// TestReadKeysStop verifies that stopping the key reader interrupts a read
+// that is waiting for a key
+func TestReadKeysStop(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+
+	keys, stop := readKeys(int(r.Fd()))
+	if _, err := w.Write([]byte("q")); err != nil {
+		t.Fatal(err)
+	}
+	if key := <-keys; key != 'q' {
+		t.Fatalf("key = %q, expected 'q'", key)
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("stop did not interrupt the pending read")
+	}
+	if _, ok := <-keys; ok {
+		t.Errorf("keys still open after stop")
+	}
+}
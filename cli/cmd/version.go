@@ -0,0 +1,61 @@
+/*
+ * Copyright (c) 2026, Psiphon Inc.
+ * All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/Psiphon-Inc/conduit/cli/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Show version and embedded config information",
+	Long: `Show the Conduit version and, for builds with an embedded Psiphon config,
+which config is baked in. Only the config's fingerprint and non-secret
+identifiers are shown.`,
+	Args: cobra.NoArgs,
+	RunE: runVersion,
+}
+
+func init() {
+	rootCmd.AddCommand(versionCmd)
+}
+
+func runVersion(cmd *cobra.Command, args []string) error {
+	info, err := config.EmbeddedConfigInfo()
+	if err != nil {
+		return fmt.Errorf("failed to read embedded config: %w", err)
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintf(writer, "Version:\t%s\n", version)
+	if info == nil {
+		_, _ = fmt.Fprintf(writer, "Embedded Config:\tnone\n")
+	} else {
+		_, _ = fmt.Fprintf(writer, "Embedded Config:\tsha256:%s\n", info.Fingerprint)
+		_, _ = fmt.Fprintf(writer, "Propagation Channel ID:\t%s\n", info.PropagationChannelID)
+		_, _ = fmt.Fprintf(writer, "Sponsor ID:\t%s\n", info.SponsorID)
+	}
+	return writer.Flush()
+}
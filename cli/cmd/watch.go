@@ -0,0 +1,203 @@
+/*
+ * Copyright (c) 2026, Psiphon Inc.
+ * All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Psiphon-Inc/conduit/cli/internal/logging"
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/cobra"
+)
+
+var (
+	watchConfigFiles []string
+	watchDebounce    time.Duration
+	watchStopTimeout time.Duration
+)
+
+var watchCmd = &cobra.Command{
+	Use:   "watch --config-file <file> <command> [flags]",
+	Short: "Restart a conduit command when a config file changes (development only)",
+	Long: `Run a conduit command, usually start, and restart it whenever one of the
+watched config files changes. Rapid edits are collapsed into one restart.
+
+	conduit watch --config-file psiphon_config.json start -c psiphon_config.json
+
+This is a convenience for development. Each change stops the process and
+drops its clients, and a config that fails to load leaves nothing running
+until the next edit. In production, send SIGHUP or use the control socket
+reload command instead: the running config is kept if the new one is bad.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runWatch,
+}
+
+func init() {
+	rootCmd.AddCommand(watchCmd)
+
+	// Everything from the command on belongs to the child
+	watchCmd.Flags().SetInterspersed(false)
+	watchCmd.Flags().StringSliceVar(&watchConfigFiles, "config-file", nil, "config file to watch (repeatable)")
+	watchCmd.Flags().DurationVar(&watchDebounce, "debounce", time.Second, "wait this long after the last change before restarting")
+	watchCmd.Flags().DurationVar(&watchStopTimeout, "stop-timeout", 30*time.Second, "how long the command gets to stop gracefully before it is killed")
+	_ = watchCmd.MarkFlagRequired("config-file")
+}
+
+func runWatch(cmd *cobra.Command, args []string) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to find the conduit executable: %w", err)
+	}
+
+	// Pass on the global flags given before watch
+	var childArgs []string
+	if cmd.Flags().Changed("data-dir") {
+		childArgs = append(childArgs, "--data-dir", dataDir)
+	}
+	for range verbosity {
+		childArgs = append(childArgs, "-v")
+	}
+	childArgs = append(childArgs, args...)
+	name := watchedCommandName(executable, args)
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to watch config files: %w", err)
+	}
+	defer watcher.Close()
+
+	// Watch the directories, since editors often save by replacing the file
+	watched := make(map[string]bool)
+	for _, file := range watchConfigFiles {
+		path, err := filepath.Abs(file)
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(path); err != nil {
+			return fmt.Errorf("config file %s: %w", file, err)
+		}
+		watched[path] = true
+		if err := watcher.Add(filepath.Dir(path)); err != nil {
+			return fmt.Errorf("failed to watch %s: %w", filepath.Dir(path), err)
+		}
+	}
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
+
+	logging.Printf("[INFO] Watching %s; not for production use, see conduit watch --help\n", strings.Join(watchConfigFiles, ", "))
+	child, exited := startWatchedChild(name, executable, childArgs)
+
+	debounce := time.NewTimer(0)
+	if !debounce.Stop() {
+		<-debounce.C
+	}
+
+	for {
+		select {
+		case sig := <-sigChan:
+			return stopWatchedChild(child, exited, sig)
+
+		case err := <-exited:
+			// Wait for a fix rather than restarting in a loop
+			if err != nil {
+				logging.Printf("[WARN] %s exited: %v; waiting for a config change\n", name, err)
+			} else {
+				logging.Printf("[INFO] %s exited; waiting for a config change\n", name)
+			}
+			child, exited = nil, nil
+
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return errors.New("config file watcher closed")
+			}
+			if watched[filepath.Clean(event.Name)] && event.Op != fsnotify.Chmod {
+				debounce.Reset(watchDebounce)
+			}
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return errors.New("config file watcher closed")
+			}
+			logging.Printf("[WARN] Config file watcher error: %v\n", err)
+
+		case <-debounce.C:
+			logging.Printf("[INFO] Config changed, restarting %s\n", name)
+			if err := stopWatchedChild(child, exited, syscall.SIGTERM); err != nil {
+				logging.Printf("[WARN] %s stopped with: %v\n", name, err)
+			}
+			child, exited = startWatchedChild(name, executable, childArgs)
+		}
+	}
+}
+
+// watchedCommandName names the child in logs, e.g. "conduit start". args
+// may start with flags, so the subcommand is looked up rather than taken
+// from args[0].
+func watchedCommandName(executable string, args []string) string {
+	if c, _, err := rootCmd.Find(args); err == nil && c != rootCmd {
+		return c.CommandPath()
+	}
+	return filepath.Base(executable)
+}
+
+// startWatchedChild starts conduit with args, calling it name in logs. The
+// returned channel receives the result once the process exits; both are nil
+// if it could not start.
+func startWatchedChild(name, executable string, args []string) (*exec.Cmd, chan error) {
+	child := exec.Command(executable, args...)
+	child.Stdin = os.Stdin
+	child.Stdout = os.Stdout
+	child.Stderr = os.Stderr
+	if err := child.Start(); err != nil {
+		logging.Printf("[ERROR] Failed to start %s: %v\n", name, err)
+		return nil, nil
+	}
+
+	exited := make(chan error, 1)
+	go func() { exited <- child.Wait() }()
+	return child, exited
+}
+
+// stopWatchedChild sends sig to a running child and waits for it to exit,
+// killing it after --stop-timeout
+func stopWatchedChild(child *exec.Cmd, exited chan error, sig os.Signal) error {
+	if child == nil {
+		return nil
+	}
+	_ = child.Process.Signal(sig)
+	select {
+	case err := <-exited:
+		return err
+	case <-time.After(watchStopTimeout):
+		_ = child.Process.Kill()
+		<-exited
+		return fmt.Errorf("did not stop within %s, killed", watchStopTimeout)
+	}
+}
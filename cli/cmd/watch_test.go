@@ -0,0 +1,41 @@
+/*
+ * Copyright (c) 2026, Psiphon Inc.
+ * All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import "testing"
+
+func TestWatchedCommandName(t *testing.T) {
+	tests := []struct {
+		args     []string
+		expected string
+	}{
+		{args: []string{"start", "-c", "psiphon_config.json"}, expected: "conduit start"},
+		{args: []string{"--data-dir", "/tmp/conduit", "start"}, expected: "conduit start"},
+		{args: []string{"-v", "stats", "diff", "a.json", "b.json"}, expected: "conduit stats diff"},
+		{args: []string{"--data-dir", "/tmp/conduit"}, expected: "conduit"},
+		{args: []string{"no-such-command"}, expected: "conduit"},
+	}
+
+	for _, test := range tests {
+		if got := watchedCommandName("/usr/local/bin/conduit", test.args); got != test.expected {
+			t.Errorf("watchedCommandName(%q) = %q, expected %q", test.args, got, test.expected)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+/*
+ * Copyright (c) 2026, Psiphon Inc.
+ * All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package conduit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"time"
+)
+
+// WriteDiagnostics writes a bug report bundle to a timestamped file in the
+// data dir and returns its path: the status and stats rows as the control
+// socket reports them, the current metrics, runtime counters, and the full
+// stack of every goroutine. The service keeps running. Like goroutine
+// profiles, a read-only run's bundle goes to the system temp dir.
+func (s *Service) WriteDiagnostics() (string, error) {
+	now := time.Now().UTC()
+	var report bytes.Buffer
+
+	fmt.Fprintf(&report, "Conduit diagnostics, %s\n", now.Format(time.RFC3339))
+	fmt.Fprintf(&report, "Go %s %s/%s, GOMAXPROCS %d, pid %d\n",
+		runtime.Version(), runtime.GOOS, runtime.GOARCH, runtime.GOMAXPROCS(0), os.Getpid())
+
+	writeSection(&report, "Status")
+	writeJSON(&report, s.getStatus())
+
+	writeSection(&report, "Stats")
+	writeJSON(&report, s.getInstanceStats())
+
+	writeSection(&report, "Runtime")
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	fmt.Fprintf(&report, "goroutines: %d\n", runtime.NumGoroutine())
+	fmt.Fprintf(&report, "heap in use: %d bytes\n", mem.HeapInuse)
+	fmt.Fprintf(&report, "total from OS: %d bytes\n", mem.Sys)
+	fmt.Fprintf(&report, "GC cycles: %d\n", mem.NumGC)
+	if open, limit, ok := openFDs(); ok {
+		fmt.Fprintf(&report, "open files: %d of %d\n", open, limit)
+	}
+
+	writeSection(&report, "Metrics")
+	if s.metrics == nil {
+		report.WriteString("(metrics disabled)\n")
+	} else if err := s.metrics.WriteText(&report); err != nil {
+		fmt.Fprintf(&report, "(failed: %v)\n", err)
+	}
+
+	writeSection(&report, "Goroutines")
+	if err := pprof.Lookup("goroutine").WriteTo(&report, 2); err != nil {
+		fmt.Fprintf(&report, "(failed: %v)\n", err)
+	}
+
+	dir := s.config.DataDir
+	if s.config.TempDataDir {
+		dir = os.TempDir()
+	}
+	path := filepath.Join(dir, fmt.Sprintf("diagnostics-%s.txt", now.Format("20060102-150405")))
+	return path, s.writeFile(path, report.Bytes())
+}
+
+// writeSection starts a section of the diagnostics report
+func writeSection(report *bytes.Buffer, title string) {
+	fmt.Fprintf(report, "\n== %s ==\n\n", title)
+}
+
+// writeJSON adds v to the diagnostics report as indented JSON
+func writeJSON(report *bytes.Buffer, v any) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		fmt.Fprintf(report, "(failed: %v)\n", err)
+		return
+	}
+	report.Write(data)
+	report.WriteString("\n")
+}
@@ -0,0 +1,69 @@
+/*
+ * Copyright (c) 2026, Psiphon Inc.
+ * All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package conduit
+
+import (
+	"context"
+	"time"
+
+	"github.com/Psiphon-Inc/conduit/cli/internal/logging"
+)
+
+// fdCheckInterval is how often open file descriptors are compared to the limit
+const fdCheckInterval = 10 * time.Second
+
+// monitorFDs logs and counts each time the open file descriptors rise past
+// the pressure fraction of the soft limit, until ctx is cancelled. Past the
+// limit, new client and broker connections fail with "too many open files".
+func (s *Service) monitorFDs(ctx context.Context) {
+	if _, _, ok := openFDs(); !ok {
+		return
+	}
+	underPressure := false
+
+	ticker := time.NewTicker(fdCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		used, limit, ok := openFDs()
+		if !ok {
+			continue
+		}
+		threshold := uint64(float64(limit) * s.config.FDPressure)
+
+		switch {
+		case used >= threshold && !underPressure:
+			underPressure = true
+			logging.Printf("[WARN] File descriptor pressure: %d of %d open; raise the open files limit or lower --max-clients\n", used, limit)
+			if s.metrics != nil {
+				s.metrics.IncFDPressureEvents()
+			}
+		case used < threshold && underPressure:
+			underPressure = false
+			logging.Printf("[OK] File descriptor pressure relieved: %d open\n", used)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+/*
+ * Copyright (c) 2026, Psiphon Inc.
+ * All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package conduit
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"time"
+
+	"github.com/Psiphon-Inc/conduit/cli/internal/logging"
+)
+
+// Goroutine leak watchdog tuning. Each client session (WebRTC connection
+// plus relay) accounts for a few dozen goroutines; the margins keep normal
+// churn from looking like a leak.
+const (
+	leakCheckInterval     = time.Minute
+	goroutinesPerSession  = 50
+	leakMinExcess         = 500 // Goroutines above the expected count
+	leakConsecutiveChecks = 3   // Checks in a row before reporting
+)
+
+// watchGoroutines warns and writes a goroutine profile to the data dir when
+// the goroutine count stays well above what the active sessions account
+// for, until ctx is cancelled. Another profile is only written once the
+// count has doubled since the last one, so a slow leak can't fill the disk.
+func (s *Service) watchGoroutines(ctx context.Context) {
+	// Goroutines with no clients, lowered whenever the proxy is idle
+	baseline := runtime.NumGoroutine()
+	suspect := 0
+	lastDump := 0
+
+	ticker := time.NewTicker(leakCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		goroutines := runtime.NumGoroutine()
+		sessions := int(s.connectingClients.Load() + s.connectedClients.Load())
+		if sessions == 0 {
+			baseline = min(baseline, goroutines)
+		}
+
+		expected := baseline + sessions*goroutinesPerSession
+		if goroutines-expected < leakMinExcess {
+			suspect = 0
+			continue
+		}
+		suspect++
+		if suspect < leakConsecutiveChecks || (lastDump > 0 && goroutines < 2*lastDump) {
+			continue
+		}
+
+		logging.Printf("[WARN] Possible goroutine leak: %d goroutines for %d client sessions (expected about %d)\n",
+			goroutines, sessions, expected)
+		path, err := s.dumpGoroutines()
+		if err != nil {
+			logging.Printf("[WARN] Failed to write goroutine profile: %v\n", err)
+			continue
+		}
+		lastDump = goroutines
+		logging.Printf("[INFO] Goroutine profile written to %s\n", path)
+	}
+}
+
+// dumpGoroutines writes the stacks of all goroutines, grouped by stack, to a
+// timestamped file in the data dir and returns its path. A read-only run's
+// DataDir is removed on exit, so its profiles go to the system temp dir.
+func (s *Service) dumpGoroutines() (string, error) {
+	dir := s.config.DataDir
+	if s.config.TempDataDir {
+		dir = os.TempDir()
+	}
+	path := filepath.Join(dir, fmt.Sprintf("goroutines-%s.txt", time.Now().UTC().Format("20060102-150405")))
+	var profile bytes.Buffer
+	if err := pprof.Lookup("goroutine").WriteTo(&profile, 1); err != nil {
+		return "", err
+	}
+	return path, s.writeFile(path, profile.Bytes())
+}
@@ -0,0 +1,79 @@
+/*
+ * Copyright (c) 2026, Psiphon Inc.
+ * All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package conduit
+
+import (
+	"context"
+	"fmt"
+	"runtime/metrics"
+	"time"
+
+	"github.com/Psiphon-Inc/conduit/cli/internal/logging"
+)
+
+// memoryCheckInterval is how often memory use is compared to the limit
+const memoryCheckInterval = 10 * time.Second
+
+// Runtime metrics that make up the memory the Go memory limit counts
+const (
+	memoryTotalMetric    = "/memory/classes/total:bytes"
+	memoryReleasedMetric = "/memory/classes/heap/released:bytes"
+)
+
+// monitorMemory logs and counts each time memory use rises past the
+// pressure threshold, until ctx is cancelled. The runtime works to stay
+// under the limit by collecting more often; this makes that visible.
+func (s *Service) monitorMemory(ctx context.Context) {
+	threshold := uint64(float64(s.config.MemoryLimitBytes) * s.config.MemoryPressure)
+	samples := []metrics.Sample{{Name: memoryTotalMetric}, {Name: memoryReleasedMetric}}
+	underPressure := false
+
+	ticker := time.NewTicker(memoryCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		metrics.Read(samples)
+		used := samples[0].Value.Uint64() - samples[1].Value.Uint64()
+
+		switch {
+		case used >= threshold && !underPressure:
+			underPressure = true
+			logging.Printf("[WARN] Memory pressure: using %s of %s limit\n",
+				formatMemory(used), formatMemory(uint64(s.config.MemoryLimitBytes)))
+			if s.metrics != nil {
+				s.metrics.IncMemoryPressureEvents()
+			}
+		case used < threshold && underPressure:
+			underPressure = false
+			logging.Printf("[OK] Memory pressure relieved: using %s\n", formatMemory(used))
+		}
+	}
+}
+
+// formatMemory formats a byte count in MiB
+func formatMemory(bytes uint64) string {
+	return fmt.Sprintf("%.0f MiB", float64(bytes)/(1<<20))
+}
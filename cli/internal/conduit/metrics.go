@@ -0,0 +1,146 @@
+/*
+ * Copyright (c) 2026, Psiphon Inc.
+ * All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package conduit
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Psiphon-Inc/conduit/cli/internal/config"
+	"github.com/Psiphon-Inc/conduit/cli/internal/logging"
+	"github.com/Psiphon-Inc/conduit/cli/internal/metrics"
+)
+
+// Metrics holds the process's metrics. They outlive each Service, which is
+// recreated on every restart, reload and config failover, so that counters
+// keep counting and the metrics endpoint stays up in between.
+type Metrics struct {
+	*metrics.Metrics
+	current atomic.Pointer[Service] // The running service, for scrape-time gauges
+}
+
+// NewMetrics creates the metrics for cfg, or returns nil if nothing exports
+// them: the metrics endpoint, control socket, InfluxDB and remote write
+// pushes and dashboard can each export metrics on their own
+func NewMetrics(cfg *config.Config) *Metrics {
+	if cfg.MetricsAddr == "" && cfg.ControlSocket == "" && cfg.InfluxAddr == "" && cfg.RemoteWriteURL == "" && cfg.DashboardAddr == "" {
+		return nil
+	}
+	pm := &Metrics{}
+	m, err := metrics.New(metrics.GaugeFuncs{
+		GetUptimeSeconds: func() float64 {
+			if s := pm.current.Load(); s != nil {
+				return s.getUptimeSeconds()
+			}
+			return 0
+		},
+		GetIdleSeconds: func() float64 {
+			if s := pm.current.Load(); s != nil {
+				return s.getIdleSecondsFloat()
+			}
+			return 0
+		},
+	}, cfg.MetricLabels)
+	if err != nil {
+		// Conflicting metrics are left out; the rest still work
+		logging.Printf("[WARN] Some metrics are unavailable: %v\n", err)
+	}
+	if cfg.MetricsPrivacy {
+		m.EnablePrivacy()
+	}
+	pm.Metrics = m
+	return pm
+}
+
+// Serve starts the metrics endpoint and the InfluxDB and remote write
+// pushes configured in cfg. stop pushes the final values, then shuts the
+// endpoint down.
+func (pm *Metrics) Serve(cfg *config.Config) (stop func(), err error) {
+	if pm == nil {
+		return func() {}, nil
+	}
+
+	if cfg.MetricsAddr != "" {
+		if err := pm.StartServer(cfg.MetricsAddr); err != nil {
+			return nil, fmt.Errorf("failed to start metrics server: %w", err)
+		}
+		if path, ok := metrics.UnixSocketPath(cfg.MetricsAddr); ok {
+			logging.Printf("[OK] Prometheus metrics available at /metrics on unix socket %s\n", path)
+		} else {
+			logging.Printf("[OK] Prometheus metrics available at http://%s/metrics\n", cfg.MetricsAddr)
+		}
+	}
+
+	ctx, stopPushes := context.WithCancel(context.Background())
+	var pushes sync.WaitGroup
+	if cfg.InfluxAddr != "" {
+		pushes.Add(1)
+		go func() {
+			defer pushes.Done()
+			pm.PushInflux(ctx, metrics.InfluxOptions{
+				Addr:     cfg.InfluxAddr,
+				Interval: cfg.InfluxInterval,
+				Tags:     map[string]string{"instance": instanceName(cfg)},
+				FileMode: cfg.FileMode,
+			})
+		}()
+		logging.Printf("[OK] Pushing metrics to %s every %s\n", cfg.InfluxAddr, cfg.InfluxInterval)
+	}
+	if cfg.RemoteWriteURL != "" {
+		pushes.Add(1)
+		go func() {
+			defer pushes.Done()
+			pm.PushRemoteWrite(ctx, metrics.RemoteWriteOptions{
+				URL:         cfg.RemoteWriteURL,
+				Interval:    cfg.RemoteWriteInterval,
+				BearerToken: cfg.RemoteWriteToken,
+				Labels:      map[string]string{"job": "conduit", "instance": instanceName(cfg)},
+			})
+		}()
+		// Validated by config; the password is kept out of the log
+		remoteURL, _ := url.Parse(cfg.RemoteWriteURL)
+		logging.Printf("[OK] Pushing metrics to %s every %s\n", remoteURL.Redacted(), cfg.RemoteWriteInterval)
+	}
+
+	return func() {
+		stopPushes()
+		pushes.Wait()
+		if cfg.MetricsAddr == "" {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := pm.Shutdown(ctx); err != nil {
+			logging.Printf("[ERROR] Failed to shutdown metrics server: %v\n", err)
+		}
+	}, nil
+}
+
+// attach makes s the service the metrics describe, starting its gauges from
+// empty
+func (pm *Metrics) attach(s *Service) {
+	pm.ResetService()
+	pm.SetConfig(s.config.MaxClients, s.config.BandwidthBytesPerSecond)
+	pm.current.Store(s)
+}
@@ -0,0 +1,112 @@
+/*
+ * Copyright (c) 2026, Psiphon Inc.
+ * All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package conduit
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Psiphon-Inc/conduit/cli/internal/config"
+)
+
+// TestMetricsSurviveRestart verifies that counters recorded by one service
+// are still scraped once the next one has replaced it
+func TestMetricsSurviveRestart(t *testing.T) {
+	cfg := &config.Config{MetricsAddr: "127.0.0.1:9090", MaxClients: 50}
+	m := NewMetrics(cfg)
+	server := httptest.NewServer(m.Handler())
+	defer server.Close()
+
+	first, err := New(cfg, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first.connectedClients.Store(3)
+	first.metrics.SetConnectedClients(3)
+	first.droppedClients.Store(3)
+	first.reportDroppedClients()
+
+	if _, err := New(cfg, m); err != nil {
+		t.Fatal(err)
+	}
+
+	body := scrape(t, server)
+	for _, want := range []string{
+		"\nconduit_force_dropped_clients_total 3\n",
+		"\nconduit_connected_clients 0\n",
+		"\nconduit_max_clients 50\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected %q after the restart in:\n%s", strings.TrimSpace(want), body)
+		}
+	}
+}
+
+// TestRestartCountersSurviveRestart verifies that the counters recorded
+// around restarts and reloads accumulate across services
+func TestRestartCountersSurviveRestart(t *testing.T) {
+	cfg := &config.Config{MetricsAddr: "127.0.0.1:9090"}
+	m := NewMetrics(cfg)
+	server := httptest.NewServer(m.Handler())
+	defer server.Close()
+
+	for range 2 {
+		service, err := New(cfg, m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		service.RecordConfigReloadFailure()
+		// The replacement records the restart
+		service, err = New(cfg, m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		service.RecordUnhealthyRestart()
+		service.RecordConfigFailover()
+	}
+
+	body := scrape(t, server)
+	for _, want := range []string{
+		"\nconduit_unhealthy_restarts_total 2\n",
+		"\nconduit_config_reload_failures_total 2\n",
+		"\nconduit_config_failover_total 2\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected %q after the restarts in:\n%s", strings.TrimSpace(want), body)
+		}
+	}
+}
+
+// scrape returns the metrics page served by server
+func scrape(t *testing.T, server *httptest.Server) string {
+	t.Helper()
+	resp, err := server.Client().Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
@@ -0,0 +1,107 @@
+/*
+ * Copyright (c) 2026, Psiphon Inc.
+ * All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package conduit
+
+import (
+	"context"
+	"net"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Psiphon-Inc/conduit/cli/internal/logging"
+)
+
+// netChangeCheckInterval is how often local interface addresses are compared
+const netChangeCheckInterval = 10 * time.Second
+
+// monitorNetworkChanges resets the broker session whenever the set of local
+// addresses changes (e.g. a floating IP moves), until ctx is cancelled.
+// Without this, the proxy keeps announcing over connections bound to an
+// address the host no longer has until they time out.
+func (s *Service) monitorNetworkChanges(ctx context.Context) {
+	previous, err := localAddrs()
+	if err != nil {
+		logging.Printf("[WARN] Network change detection disabled: %v\n", err)
+		return
+	}
+
+	ticker := time.NewTicker(netChangeCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		current, err := localAddrs()
+		if err != nil {
+			logging.Printf("[WARN] Failed to list network addresses: %v\n", err)
+			continue
+		}
+		if slices.Equal(current, previous) {
+			continue
+		}
+
+		logging.Printf("[INFO] Network change detected: [%s] -> [%s]\n",
+			strings.Join(previous, " "), strings.Join(current, " "))
+		previous = current
+
+		// Errors are reported by tunnel-core as notices; the proxy goes
+		// back to announcing on the new address once the reset completes
+		s.controller.NetworkChanged()
+		s.resetFirstClient()
+		logging.Println("[OK] Re-registering with broker after network change")
+	}
+}
+
+// resetFirstClient restarts time-to-first-client tracking for a new broker
+// session (thread-safe)
+func (s *Service) resetFirstClient() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.LiveTime = time.Now()
+	s.stats.FirstClientTime = time.Time{}
+	if s.metrics != nil {
+		s.metrics.SetTimeToFirstClient(0)
+	}
+}
+
+// localAddrs returns the sorted global unicast addresses of the local interfaces
+func localAddrs() ([]string, error) {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []string
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || !ipNet.IP.IsGlobalUnicast() {
+			continue
+		}
+		result = append(result, ipNet.IP.String())
+	}
+	slices.Sort(result)
+
+	return result, nil
+}
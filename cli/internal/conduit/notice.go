@@ -0,0 +1,82 @@
+/*
+ * Copyright (c) 2026, Psiphon Inc.
+ * All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package conduit
+
+import (
+	"context"
+	"time"
+
+	"github.com/Psiphon-Inc/conduit/cli/internal/logging"
+	"github.com/Psiphon-Inc/conduit/cli/internal/notice"
+)
+
+// noticeCheckInterval is how often the operator notice is fetched
+const noticeCheckInterval = time.Hour
+
+// monitorOperatorNotice fetches the operator notice now and then every
+// noticeCheckInterval until ctx is cancelled, logging it when it changes.
+// The notice is informational only and never affects the proxy.
+func (s *Service) monitorOperatorNotice(ctx context.Context) {
+	ticker := time.NewTicker(noticeCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		n, err := notice.Fetch(ctx, s.config.NoticeURL, s.config.NoticeKey)
+		if err != nil {
+			// Keep the last good notice rather than dropping it on a
+			// transient failure or a spoofed document
+			if ctx.Err() == nil {
+				logging.Printf("[WARN] Operator notice: %v\n", err)
+			}
+		} else {
+			s.setOperatorNotice(n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// setOperatorNotice records the current notice (nil = none), logging it if
+// it changed (thread-safe)
+func (s *Service) setOperatorNotice(n *notice.Notice) {
+	s.mu.Lock()
+	previous := s.operatorNotice
+	s.operatorNotice = n
+	s.mu.Unlock()
+
+	if s.metrics != nil {
+		s.metrics.SetOperatorNotice(n != nil)
+	}
+
+	switch {
+	case n != nil && (previous == nil || *n != *previous):
+		if n.URL != "" {
+			logging.Printf("[NOTICE] %s (%s)\n", n.Message, n.URL)
+		} else {
+			logging.Printf("[NOTICE] %s\n", n.Message)
+		}
+	case n == nil && previous != nil:
+		logging.Printf("[INFO] Operator notice withdrawn\n")
+	}
+}
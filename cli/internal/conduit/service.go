@@ -26,14 +26,19 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"runtime/debug"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/Psiphon-Inc/conduit/cli/internal/config"
+	"github.com/Psiphon-Inc/conduit/cli/internal/control"
 	"github.com/Psiphon-Inc/conduit/cli/internal/geo"
 	"github.com/Psiphon-Inc/conduit/cli/internal/logging"
 	"github.com/Psiphon-Inc/conduit/cli/internal/metrics"
+	"github.com/Psiphon-Inc/conduit/cli/internal/notice"
+	"github.com/Psiphon-Inc/conduit/cli/internal/systemd"
 	"github.com/Psiphon-Labs/psiphon-tunnel-core/psiphon"
 	"github.com/Psiphon-Labs/psiphon-tunnel-core/psiphon/common/inproxy"
 )
@@ -41,6 +46,18 @@ import (
 // ErrIdleRestart is returned when the service should restart due to idle timeout
 var ErrIdleRestart = errors.New("idle restart triggered")
 
+// ErrUnhealthyRestart is returned when the service should restart because it
+// never went live with the broker
+var ErrUnhealthyRestart = errors.New("unhealthy restart triggered")
+
+// ErrMustUpgrade is returned when the broker rejected this version of conduit.
+// Restarting won't help; only an upgrade will.
+var ErrMustUpgrade = errors.New("the Psiphon broker requires a newer version of conduit; upgrade to keep running a proxy")
+
+// serviceStarts counts the services started in this process, so that the
+// roundrobin fingerprint mode moves to the next profile on each restart
+var serviceStarts atomic.Int64
+
 // Service represents the Conduit inproxy service
 type Service struct {
 	config               *config.Config
@@ -48,16 +65,24 @@ type Service struct {
 	stats                *Stats
 	geoCollector         *geo.Collector
 	metrics              *metrics.Metrics
+	control              *control.Server
 	mu                   sync.RWMutex
 	lastActivityLogTime  time.Time
 	lastLoggedAnnouncing int
 	lastLoggedConnecting int
 	lastLoggedConnected  int
+	operatorNotice       *notice.Notice // Guarded by mu
+	reload               func() error   // Set by SetReloadHandler before Run
+	configEpoch          int            // Set by SetConfigEpoch before Run
+	throttleTime         time.Time      // When the broker asked to back off (zero = not backing off); guarded by mu
+	throttleBackoff      time.Duration  // Delay tunnel-core reported before announcing again; guarded by mu
 
 	startTimeUnixNano  int64
 	lastActiveUnixNano atomic.Int64
 	connectingClients  atomic.Int64
 	connectedClients   atomic.Int64
+	droppedClients     atomic.Int64 // Clients connected when shutdown began
+	mustUpgrade        atomic.Bool  // The broker asked for a newer conduit
 }
 
 // Stats tracks proxy activity statistics
@@ -69,11 +94,19 @@ type Stats struct {
 	TotalBytesDown    int64
 	StartTime         time.Time
 	LastActiveTime    time.Time // Last time there was at least one client (connecting or connected)
+	PeriodBytes       int64     // Bytes transferred in the most recent activity period
+	PeriodTime        time.Time // When the most recent activity period was reported
+	LiveTime          time.Time // When the proxy last (re)registered with the broker
+	FirstClientTime   time.Time // When the first client connected after LiveTime (zero = none yet)
+	PeakClients       int       // Most clients connected at once since StartTime
+	PeakSinceReset    int       // Most clients connected at once since PeakResetTime
+	PeakResetTime     time.Time // When the peak was last reset (zero = never)
 	IsLive            bool      // Connected to broker and ready to accept clients
 }
 
 // StatsJSON represents the JSON structure for persisted stats
 type StatsJSON struct {
+	SchemaVersion     int          `json:"schemaVersion"`
 	Announcing        int          `json:"announcing"`
 	ConnectingClients int          `json:"connectingClients"`
 	ConnectedClients  int          `json:"connectedClients"`
@@ -82,12 +115,52 @@ type StatsJSON struct {
 	UptimeSeconds     int64        `json:"uptimeSeconds"`
 	IdleSeconds       int64        `json:"idleSeconds"`
 	IsLive            bool         `json:"isLive"`
+	PeakClients       int          `json:"peakClients"`
+	PeakSinceReset    int          `json:"peakClientsSinceReset"`
+	PeakResetTime     string       `json:"peakResetTimestamp,omitempty"`
+	Name              string       `json:"name,omitempty"`
+	TimeToFirstClient *int64       `json:"timeToFirstClientSeconds,omitempty"`
 	Geo               []geo.Result `json:"geo,omitempty"`
 	Timestamp         string       `json:"timestamp"`
 }
 
-// New creates a new Conduit service
-func New(cfg *config.Config) (*Service, error) {
+// StatsSchemaVersion is the version of the StatsJSON layout. Adding fields
+// is compatible and keeps the version; removing or renaming a field, or
+// changing what it means, bumps it. Stats written before the version was
+// recorded read as version 0 and have the same layout as version 1.
+const StatsSchemaVersion = 1
+
+// CheckSchema reports whether stats read back from a file or the control
+// socket can be interpreted by this build. Unknown fields from newer
+// compatible versions are ignored and missing ones are left zero.
+func (s StatsJSON) CheckSchema() error {
+	if s.SchemaVersion > StatsSchemaVersion {
+		return fmt.Errorf("stats schema version %d is newer than this conduit understands (%d); upgrade conduit", s.SchemaVersion, StatsSchemaVersion)
+	}
+	return nil
+}
+
+// StatusJSON is the control socket status response: the stats snapshot plus
+// the limits the service is running with
+type StatusJSON struct {
+	StatsJSON
+	MaxClients              int            `json:"maxClients"`
+	BandwidthBytesPerSecond int            `json:"bandwidthBytesPerSecond"`
+	Canary                  bool           `json:"canary,omitempty"`
+	OperatorNotice          *notice.Notice `json:"operatorNotice,omitempty"`
+	ConfigEpoch             int            `json:"configEpoch"`
+	BrokerThrottle          *ThrottleJSON  `json:"brokerThrottle,omitempty"`
+	MustUpgrade             bool           `json:"mustUpgrade,omitempty"` // The broker rejected this version; the proxy has stopped
+}
+
+// ThrottleJSON describes a broker request to back off that is still in effect
+type ThrottleJSON struct {
+	Since          string  `json:"since"`
+	BackoffSeconds float64 `json:"backoffSeconds"` // tunnel-core's delay before it announces again
+}
+
+// New creates a new Conduit service, reporting to m (which may be nil)
+func New(cfg *config.Config, m *Metrics) (*Service, error) {
 	s := &Service{
 		config: cfg,
 		stats: &Stats{
@@ -96,49 +169,95 @@ func New(cfg *config.Config) (*Service, error) {
 	}
 	s.startTimeUnixNano = s.stats.StartTime.UnixNano()
 
-	if cfg.MetricsAddr != "" {
-		s.metrics = metrics.New(metrics.GaugeFuncs{
-			GetUptimeSeconds: s.getUptimeSeconds,
-			GetIdleSeconds:   s.getIdleSecondsFloat,
+	if m != nil {
+		s.metrics = m.Metrics
+		m.attach(s)
+	}
+
+	if cfg.ControlSocket != "" {
+		s.control = control.New(control.Funcs{
+			GetStatus:        s.getStatus,
+			GetInstanceStats: s.getInstanceStats,
+			WriteMetrics:     s.metrics.WriteText,
+			Reload:           s.requestReload,
+			ResetPeak:        s.resetPeak,
+			Diagnostics:      s.WriteDiagnostics,
 		})
-		s.metrics.SetConfig(cfg.MaxClients, cfg.BandwidthBytesPerSecond)
 	}
 
 	return s, nil
 }
 
 // Run starts the Conduit inproxy service and blocks until context is cancelled
-// Returns ErrIdleRestart if the service should be restarted due to idle timeout
+// Returns ErrIdleRestart if the service should be restarted due to idle timeout,
+// or ErrUnhealthyRestart if it did not go live in time
 func (s *Service) Run(ctx context.Context) error {
 	if s.config.GeoEnabled {
 		dbPath := s.config.DataDir + "/GeoLite2-Country.mmdb"
 		s.geoCollector = geo.NewCollector(dbPath)
 		if err := s.geoCollector.Start(ctx); err != nil {
-			fmt.Printf("[WARN] Geo disabled: %v\n", err)
+			logging.Printf("[WARN] Geo disabled: %v\n", err)
 			s.geoCollector = nil
 		} else {
-			fmt.Println("[GEO] Tracking enabled")
+			logging.Println("[GEO] Tracking enabled")
 		}
 	}
 
-	if s.metrics != nil && s.config.MetricsAddr != "" {
-		if err := s.metrics.StartServer(s.config.MetricsAddr); err != nil {
-			return fmt.Errorf("failed to start metrics server: %w", err)
+	if s.config.DashboardAddr != "" {
+		stopDashboard, err := s.startDashboard(s.config.DashboardAddr)
+		if err != nil {
+			return err
 		}
+		defer stopDashboard()
+		logging.Printf("[OK] Dashboard available at http://%s/\n", s.config.DashboardAddr)
+	}
 
-		logging.Printf("[OK] Prometheus metrics available at http://%s/metrics\n", s.config.MetricsAddr)
+	if s.control != nil {
+		if err := s.control.Start(s.config.ControlSocket); err != nil {
+			return fmt.Errorf("failed to start control socket: %w", err)
+		}
 
-		// Ensure metrics server is shut down when we're done
-		defer func() {
-			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-			defer cancel()
+		logging.Printf("[OK] Control socket listening at %s\n", s.config.ControlSocket)
 
-			if err := s.metrics.Shutdown(ctx); err != nil {
-				logging.Printf("[ERROR] Failed to shutdown metrics server: %v\n", err)
+		defer func() {
+			s.publish(control.EventInstanceState, map[string]any{
+				"state":          "stopped",
+				"droppedClients": s.droppedClients.Load(),
+			})
+			if err := s.control.Shutdown(); err != nil {
+				logging.Printf("[ERROR] Failed to shutdown control socket: %v\n", err)
 			}
 		}()
 	}
 
+	if s.config.MemoryLimitBytes > 0 {
+		debug.SetMemoryLimit(s.config.MemoryLimitBytes)
+		logging.Printf("[OK] Memory limit: %s (pressure at %.0f%%)\n",
+			formatMemory(uint64(s.config.MemoryLimitBytes)), s.config.MemoryPressure*100)
+		monitorCtx, stopMonitor := context.WithCancel(ctx)
+		defer stopMonitor()
+		go s.monitorMemory(monitorCtx)
+	}
+	switch {
+	case s.config.ContainerMemoryBytes == 0:
+	case s.config.MemoryLimitBytes == 0:
+		logging.Printf("[INFO] Container memory limit (cgroup %s): %s; --memory-limit auto keeps the Go runtime under it\n",
+			s.config.CgroupVersion, formatMemory(uint64(s.config.ContainerMemoryBytes)))
+	case s.config.MemoryLimitBytes > s.config.ContainerMemoryBytes:
+		logging.Printf("[WARN] Memory limit %s is above the container memory limit (cgroup %s) of %s; the container will be OOM-killed before it is reached\n",
+			formatMemory(uint64(s.config.MemoryLimitBytes)), s.config.CgroupVersion, formatMemory(uint64(s.config.ContainerMemoryBytes)))
+	}
+
+	fdCtx, stopFDs := context.WithCancel(ctx)
+	defer stopFDs()
+	go s.monitorFDs(fdCtx)
+
+	if s.config.NoticeURL != "" {
+		noticeCtx, stopNotice := context.WithCancel(ctx)
+		defer stopNotice()
+		go s.monitorOperatorNotice(noticeCtx)
+	}
+
 	// Set up notice handling FIRST - before any psiphon calls
 	if err := psiphon.SetNoticeWriter(psiphon.NewNoticeReceiver(
 		func(notice []byte) {
@@ -159,9 +278,24 @@ func (s *Service) Run(ctx context.Context) error {
 		bandwidthStr = fmt.Sprintf("%.0f Mbps", float64(s.config.BandwidthBytesPerSecond)*8/1000/1000)
 	}
 	logging.Printf("[OK] Starting Psiphon Conduit (Max Clients: %d, Bandwidth: %s)\n", s.config.MaxClients, bandwidthStr)
+	if s.config.MinClientBytesPerSecond > 0 {
+		logging.Printf("[OK] Per-client bandwidth floor: %.1f Mbps (limits max clients to %d)\n",
+			float64(s.config.MinClientBytesPerSecond)*8/1000/1000, s.config.MaxClients)
+	}
 	if s.config.CompartmentID != "" {
 		logging.Printf("[OK] Personal compartment: enabled\n")
 	}
+	if s.config.Canary {
+		logging.Printf("[OK] Running as canary\n")
+	}
+	if s.config.DNSServer != "" {
+		logging.Printf("[OK] DNS server: %s (system resolver as fallback)\n", s.config.DNSServer)
+	}
+	s.publish(control.EventLimits, map[string]any{
+		"maxClients":              s.config.MaxClients,
+		"bandwidthBytesPerSecond": s.config.BandwidthBytesPerSecond,
+	})
+	s.publish(control.EventInstanceState, map[string]any{"state": "starting"})
 
 	// Open the data store
 	err = psiphon.OpenDataStore(&psiphon.Config{
@@ -178,13 +312,44 @@ func (s *Service) Run(ctx context.Context) error {
 		return fmt.Errorf("failed to create controller: %w", err)
 	}
 
-	// If idle restart is enabled, run the controller with idle monitoring
-	if s.config.IdleRestart > 0 {
+	if s.config.LeakWatchdog {
+		watchdogCtx, stopWatchdog := context.WithCancel(ctx)
+		defer stopWatchdog()
+		go s.watchGoroutines(watchdogCtx)
+	}
+
+	if s.config.ReregisterOnNetChange {
+		netChangeCtx, stopNetChange := context.WithCancel(ctx)
+		defer stopNetChange()
+		go s.monitorNetworkChanges(netChangeCtx)
+	}
+
+	// Stopping the controller tears down every relay, so note how many
+	// clients are still connected the moment shutdown begins, before the
+	// closing connections are counted down
+	shutdownNoted := make(chan struct{})
+	stopWatching := context.AfterFunc(ctx, func() {
+		s.droppedClients.Store(s.connectedClients.Load())
+		close(shutdownNoted)
+	})
+	defer func() {
+		if !stopWatching() {
+			<-shutdownNoted
+			s.reportDroppedClients()
+		}
+	}()
+
+	// If idle or unhealthy restart is enabled, run the controller with monitoring
+	if s.config.IdleRestart > 0 || s.config.UnhealthyRestart > 0 {
 		return s.runWithIdleMonitoring(ctx)
 	}
 
-	// Run the controller (blocks until context is cancelled)
+	// Run the controller (blocks until context is cancelled, or the
+	// controller gives up on its own)
 	s.controller.Run(ctx)
+	if s.mustUpgrade.Load() && ctx.Err() == nil {
+		return ErrMustUpgrade
+	}
 
 	return nil
 }
@@ -233,6 +398,32 @@ func (s *Service) createPsiphonConfig() (*psiphon.Config, error) {
 		configJSON["InproxyProxyPersonalCompartmentID"] = s.config.CompartmentID
 	}
 
+	// Prefer the user's DNS server for all resolution, falling back to the
+	// system resolver if it fails
+	if s.config.DNSServer != "" {
+		configJSON["DNSResolverAlternateServers"] = []string{s.config.DNSServer}
+		configJSON["DNSResolverPreferredAlternateServers"] = []string{s.config.DNSServer}
+		configJSON["DNSResolverPreferAlternateServerProbability"] = 1.0
+	}
+
+	// Give up sooner (or later) on a Psiphon server that is slow to accept a
+	// client's relay connection
+	if s.config.RelayDialTimeout > 0 {
+		configJSON["InproxyProxyDestinationDialTimeoutMilliseconds"] = int(s.config.RelayDialTimeout.Milliseconds())
+	}
+
+	// Vary the delay between broker announcements so that proxies started
+	// together don't announce in step
+	configJSON["InproxyProxyAnnounceDelayJitter"] = s.config.AnnounceJitter
+
+	// Pin the TLS profile for broker requests, if a fingerprint mode asks for one
+	if profile := s.config.TLSProfile(int(serviceStarts.Add(1) - 1)); profile != "" {
+		configJSON["LimitTLSProfiles"] = []string{profile}
+		if s.config.Verbosity >= 1 {
+			logging.Printf("[DEBUG] Fingerprint (%s): TLS profile %s\n", s.config.FingerprintMode, profile)
+		}
+	}
+
 	// Disable regular tunnel functionality - we're just a proxy
 	configJSON["DisableTunnels"] = true
 
@@ -243,8 +434,22 @@ func (s *Service) createPsiphonConfig() (*psiphon.Config, error) {
 	// Enable activity notices for stats
 	configJSON["EmitInproxyProxyActivity"] = true
 
-	// Enable diagnostic notices only in verbose modes
-	configJSON["EmitDiagnosticNotices"] = s.config.Verbosity >= 1
+	// Fail broker requests that take this long beyond the time the broker may
+	// hold them. The broker holds an announcement until it has a client for
+	// it, for up to brokerAnnounceHold.
+	if s.config.BrokerRequestTimeout > 0 {
+		configJSON["InproxyProxyAnswerRequestTimeoutMilliseconds"] = int(s.config.BrokerRequestTimeout.Milliseconds())
+		configJSON["InproxyProxyAnnounceRequestTimeoutMilliseconds"] = int((brokerAnnounceHold + s.config.BrokerRequestTimeout).Milliseconds())
+	}
+
+	// Back off for at most this long after failed or throttled announcements
+	if s.config.AnnounceMaxBackoff > 0 {
+		configJSON["InproxyProxyAnnounceMaxBackoffDelayMilliseconds"] = int(s.config.AnnounceMaxBackoff.Milliseconds())
+	}
+
+	// Diagnostic notices carry the broker's requests to back off. They are
+	// only printed in verbose modes.
+	configJSON["EmitDiagnosticNotices"] = true
 
 	// Serialize config
 	configData, err := json.Marshal(configJSON)
@@ -263,10 +468,11 @@ func (s *Service) createPsiphonConfig() (*psiphon.Config, error) {
 		return nil, fmt.Errorf("failed to commit config: %w", err)
 	}
 
-	// Set up geo tracking callback if enabled
-	if s.geoCollector != nil {
+	// Set up connection callbacks if geo tracking or the control socket needs them
+	if s.geoCollector != nil || s.control != nil {
 		psiphonConfig.OnInproxyConnectionEstablished = func(local, remote inproxy.ConnectionStats) {
-			if remote.IP == "" {
+			s.publish(control.EventClientConnect, nil)
+			if s.geoCollector == nil || remote.IP == "" {
 				return
 			}
 			if remote.CandidateType == "relay" {
@@ -276,7 +482,13 @@ func (s *Service) createPsiphonConfig() (*psiphon.Config, error) {
 			}
 		}
 		psiphonConfig.OnInproxyConnectionClosed = func(remote *inproxy.ConnectionStats, bw *inproxy.BandwidthStats) {
-			if remote == nil || remote.IP == "" || bw == nil {
+			data := map[string]any{}
+			if bw != nil {
+				data["bytesUp"] = bw.BytesUp
+				data["bytesDown"] = bw.BytesDown
+			}
+			s.publish(control.EventClientDisconnect, data)
+			if s.geoCollector == nil || remote == nil || remote.IP == "" || bw == nil {
 				return
 			}
 			if remote.CandidateType == "relay" {
@@ -299,6 +511,7 @@ func (s *Service) updateMetrics() {
 	s.metrics.SetAnnouncing(s.stats.Announcing)
 	s.metrics.SetConnectingClients(s.stats.ConnectingClients)
 	s.metrics.SetConnectedClients(s.stats.ConnectedClients)
+	s.metrics.SetPeakClients(s.stats.PeakClients, s.stats.PeakSinceReset)
 	s.metrics.SetBytesUploaded(float64(s.stats.TotalBytesUp))
 	s.metrics.SetBytesDownloaded(float64(s.stats.TotalBytesDown))
 
@@ -371,12 +584,21 @@ func (s *Service) handleNotice(notice []byte) {
 		if v, ok := noticeData.Data["connectedClients"].(float64); ok {
 			s.stats.ConnectedClients = int(v)
 		}
+		s.updatePeakLocked()
+		if s.stats.ConnectingClients > prevConnecting {
+			s.endBrokerThrottleLocked()
+		}
+		var periodBytes int64
 		if v, ok := noticeData.Data["bytesUp"].(float64); ok {
 			s.stats.TotalBytesUp += int64(v)
+			periodBytes += int64(v)
 		}
 		if v, ok := noticeData.Data["bytesDown"].(float64); ok {
 			s.stats.TotalBytesDown += int64(v)
+			periodBytes += int64(v)
 		}
+		s.stats.PeriodBytes = periodBytes
+		s.stats.PeriodTime = now
 
 		// Track last active time for idle calculation
 		if s.stats.ConnectingClients > 0 || s.stats.ConnectedClients > 0 {
@@ -384,14 +606,7 @@ func (s *Service) handleNotice(notice []byte) {
 			s.lastActiveUnixNano.Store(now.UnixNano())
 		}
 
-		becameLive := false
-		if !s.stats.IsLive && (s.stats.Announcing > 0 || s.stats.ConnectingClients > 0 || s.stats.ConnectedClients > 0) {
-			s.stats.IsLive = true
-			if s.metrics != nil {
-				s.metrics.SetIsLive(true)
-			}
-			becameLive = true
-		}
+		becameLive := s.updateLiveLocked(now)
 
 		// Log if client counts changed
 		if s.stats.ConnectingClients != prevConnecting || s.stats.ConnectedClients != prevConnected {
@@ -404,6 +619,8 @@ func (s *Service) handleNotice(notice []byte) {
 
 		s.mu.Unlock()
 		if becameLive {
+			s.publish(control.EventInstanceState, map[string]any{"state": "live"})
+			s.notifyReady()
 			logging.Println("[OK] Announcing presence to Psiphon broker, you will see announcing=1 while bootstrapping is underway")
 		}
 		if shouldLog {
@@ -428,6 +645,10 @@ func (s *Service) handleNotice(notice []byte) {
 		if v, ok := noticeData.Data["connectedClients"].(float64); ok {
 			s.stats.ConnectedClients = int(v)
 		}
+		s.updatePeakLocked()
+		if s.stats.ConnectingClients > prevConnecting {
+			s.endBrokerThrottleLocked()
+		}
 		if v, ok := noticeData.Data["totalBytesUp"].(float64); ok {
 			s.stats.TotalBytesUp = int64(v)
 		}
@@ -436,20 +657,13 @@ func (s *Service) handleNotice(notice []byte) {
 		}
 
 		// Track last active time for idle calculation
+		now := time.Now()
 		if s.stats.ConnectingClients > 0 || s.stats.ConnectedClients > 0 {
-			now := time.Now()
 			s.stats.LastActiveTime = now
 			s.lastActiveUnixNano.Store(now.UnixNano())
 		}
 
-		becameLive := false
-		if !s.stats.IsLive && (s.stats.Announcing > 0 || s.stats.ConnectingClients > 0 || s.stats.ConnectedClients > 0) {
-			s.stats.IsLive = true
-			if s.metrics != nil {
-				s.metrics.SetIsLive(true)
-			}
-			becameLive = true
-		}
+		becameLive := s.updateLiveLocked(now)
 
 		// Log if client counts changed
 		if s.stats.ConnectingClients != prevConnecting || s.stats.ConnectedClients != prevConnected {
@@ -461,6 +675,8 @@ func (s *Service) handleNotice(notice []byte) {
 
 		s.mu.Unlock()
 		if becameLive {
+			s.publish(control.EventInstanceState, map[string]any{"state": "live"})
+			s.notifyReady()
 			logging.Println("[OK] Announcing to Psiphon broker")
 		}
 
@@ -472,9 +688,16 @@ func (s *Service) handleNotice(notice []byte) {
 		}
 
 	case "InproxyMustUpgrade":
-		logging.Printf("WARNING: A newer version of Conduit is required. Please upgrade.\n")
+		s.setMustUpgrade()
 
 	case "Error":
+		if backoff, ok := brokerLimited(noticeData.Data); ok {
+			s.startBrokerThrottle(backoff)
+		}
+		if brokerTimedOut(noticeData.Data) && s.metrics != nil {
+			s.metrics.IncBrokerTimeouts()
+		}
+
 		// Handle errors based on verbosity
 		if s.config.Verbosity >= 1 {
 			if errMsg, ok := noticeData.Data["error"].(string); ok {
@@ -495,32 +718,268 @@ func (s *Service) handleNotice(notice []byte) {
 // logStats logs the current proxy statistics (must be called with lock held)
 func (s *Service) logStats() {
 	uptime := time.Since(s.stats.StartTime).Truncate(time.Second)
-	fmt.Printf("%s [STATS] Connecting: %d | Connected: %d | Up: %s | Down: %s | Uptime: %s\n",
-		time.Now().Format("2006-01-02 15:04:05"),
+	logging.Printf("[STATS] Connecting: %d | Connected: %d | Up: %s | Down: %s | Uptime: %s\n",
 		s.stats.ConnectingClients,
 		s.stats.ConnectedClients,
-		formatBytes(s.stats.TotalBytesUp),
-		formatBytes(s.stats.TotalBytesDown),
+		FormatBytes(s.stats.TotalBytesUp),
+		FormatBytes(s.stats.TotalBytesDown),
 		formatDuration(uptime),
 	)
 
 	// Write stats to file if configured (copy data while locked, write async)
 	if s.config.StatsFile != "" {
-		statsJSON := StatsJSON{
-			Announcing:        s.stats.Announcing,
-			ConnectingClients: s.stats.ConnectingClients,
-			ConnectedClients:  s.stats.ConnectedClients,
-			TotalBytesUp:      s.stats.TotalBytesUp,
-			TotalBytesDown:    s.stats.TotalBytesDown,
-			UptimeSeconds:     int64(time.Since(s.stats.StartTime).Seconds()),
-			IdleSeconds:       int64(s.calcIdleSeconds()),
-			IsLive:            s.stats.IsLive,
-			Timestamp:         time.Now().Format(time.RFC3339),
+		go s.writeStatsToFile(s.statsJSONLocked())
+	}
+}
+
+// statsJSONLocked builds a snapshot of the current stats. Must be called with lock held.
+func (s *Service) statsJSONLocked() StatsJSON {
+	statsJSON := StatsJSON{
+		SchemaVersion:     StatsSchemaVersion,
+		Announcing:        s.stats.Announcing,
+		ConnectingClients: s.stats.ConnectingClients,
+		ConnectedClients:  s.stats.ConnectedClients,
+		TotalBytesUp:      s.stats.TotalBytesUp,
+		TotalBytesDown:    s.stats.TotalBytesDown,
+		UptimeSeconds:     int64(time.Since(s.stats.StartTime).Seconds()),
+		IdleSeconds:       int64(s.calcIdleSeconds()),
+		IsLive:            s.stats.IsLive,
+		PeakClients:       s.stats.PeakClients,
+		PeakSinceReset:    s.stats.PeakSinceReset,
+		Name:              s.config.InstanceName,
+		Timestamp:         time.Now().Format(time.RFC3339),
+	}
+	if !s.stats.FirstClientTime.IsZero() {
+		seconds := int64(s.stats.FirstClientTime.Sub(s.stats.LiveTime).Seconds())
+		statsJSON.TimeToFirstClient = &seconds
+	}
+	if !s.stats.PeakResetTime.IsZero() {
+		statsJSON.PeakResetTime = s.stats.PeakResetTime.Format(time.RFC3339)
+	}
+	if s.geoCollector != nil {
+		statsJSON.Geo = s.geoCollector.GetResults()
+	}
+	return statsJSON
+}
+
+// getStatus returns the current stats snapshot (thread-safe, for the control socket)
+func (s *Service) getStatus() any {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return StatusJSON{
+		StatsJSON:               s.statsJSONLocked(),
+		MaxClients:              s.config.MaxClients,
+		BandwidthBytesPerSecond: s.config.BandwidthBytesPerSecond,
+		Canary:                  s.config.Canary,
+		OperatorNotice:          s.operatorNotice,
+		ConfigEpoch:             s.configEpoch,
+		BrokerThrottle:          s.throttleJSONLocked(),
+		MustUpgrade:             s.mustUpgrade.Load(),
+	}
+}
+
+// brokerAnnounceHold is how long the broker holds an announcement waiting for
+// a client before it answers with no match (its default, tactics can change it)
+const brokerAnnounceHold = 2 * time.Minute
+
+// activityPeriod is how often tunnel-core reports proxy activity. Periods
+// with no activity are not reported at all.
+const activityPeriod = time.Second
+
+// instanceName returns --instance-name, or inst-0 if unnamed
+func instanceName(cfg *config.Config) string {
+	if cfg.InstanceName == "" {
+		return config.DefaultInstanceName
+	}
+	return cfg.InstanceName
+}
+
+// getInstanceStats returns the stats command rows (thread-safe, for the control socket)
+func (s *Service) getInstanceStats() []control.InstanceStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	state := "starting"
+	if s.stats.IsLive {
+		state = "live"
+	}
+
+	// A missed report means the last period was followed by idle ones
+	var throughput int64
+	if time.Since(s.stats.PeriodTime) < 2*activityPeriod {
+		throughput = s.stats.PeriodBytes * int64(time.Second) / int64(activityPeriod)
+	}
+
+	return []control.InstanceStats{{
+		Index:       0,
+		Name:        instanceName(s.config),
+		State:       state,
+		Clients:     s.stats.ConnectedClients,
+		BytesUp:     s.stats.TotalBytesUp,
+		BytesDown:   s.stats.TotalBytesDown,
+		Throughput:  throughput,
+		PeakClients: s.stats.PeakSinceReset,
+	}}
+}
+
+// updateLiveLocked marks the service live once it announces or has clients,
+// and records its first client. It reports whether the service just went
+// live. Must be called with lock held.
+func (s *Service) updateLiveLocked(now time.Time) bool {
+	becameLive := false
+	if !s.stats.IsLive && (s.stats.Announcing > 0 || s.stats.ConnectingClients > 0 || s.stats.ConnectedClients > 0) {
+		s.stats.IsLive = true
+		s.stats.LiveTime = now
+		if s.metrics != nil {
+			s.metrics.SetIsLive(true)
 		}
-		if s.geoCollector != nil {
-			statsJSON.Geo = s.geoCollector.GetResults()
+		becameLive = true
+	}
+
+	// Time to first client reflects how quickly the broker matched
+	// this proxy once it registered
+	if s.stats.IsLive && s.stats.FirstClientTime.IsZero() && s.stats.ConnectedClients > 0 {
+		s.stats.FirstClientTime = now
+		if s.metrics != nil {
+			s.metrics.SetTimeToFirstClient(now.Sub(s.stats.LiveTime))
 		}
-		go s.writeStatsToFile(statsJSON)
+	}
+	return becameLive
+}
+
+// updatePeakLocked raises the peak clients to the current count. Must be called with lock held.
+func (s *Service) updatePeakLocked() {
+	s.stats.PeakClients = max(s.stats.PeakClients, s.stats.ConnectedClients)
+	s.stats.PeakSinceReset = max(s.stats.PeakSinceReset, s.stats.ConnectedClients)
+}
+
+// brokerLimited reports whether an error notice is tunnel-core giving up on
+// an announcement because the broker answered that this proxy is limited,
+// and how long tunnel-core backs off before announcing again. tunnel-core
+// only reports the first few of each error per half hour.
+func brokerLimited(data map[string]interface{}) (time.Duration, bool) {
+	message, _ := data["message"].(string)
+	errMsg, _ := data["error"].(string)
+	if message != "proxy client failed" || (errMsg != "limited" && !strings.HasSuffix(errMsg, ": limited")) {
+		return 0, false
+	}
+	delayText, _ := data["delay"].(string)
+	delay, _ := time.ParseDuration(delayText)
+	return delay, true
+}
+
+// brokerTimedOut reports whether an error notice is tunnel-core giving up on
+// a broker request that ran out of time. Like throttling, tunnel-core only
+// reports the first few of each error per half hour.
+func brokerTimedOut(data map[string]interface{}) bool {
+	message, _ := data["message"].(string)
+	errMsg, _ := data["error"].(string)
+	return message == "proxy client failed" &&
+		strings.Contains(errMsg, "(*BrokerClient)") &&
+		strings.HasSuffix(errMsg, context.DeadlineExceeded.Error())
+}
+
+// startBrokerThrottle records a broker request to back off. Each announcement
+// worker reports its own, so only the first is logged.
+func (s *Service) startBrokerThrottle(backoff time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	started := s.throttleTime.IsZero()
+	if started {
+		s.throttleTime = time.Now()
+	}
+	s.throttleBackoff = backoff
+	if s.metrics != nil {
+		s.metrics.SetBrokerThrottle(true, backoff)
+	}
+	if started {
+		logging.Printf("[WARN] Broker asked this proxy to back off; announcing again in %s\n", backoff)
+	}
+}
+
+// endBrokerThrottleLocked clears a broker request to back off once the broker
+// matches a client again. Must be called with lock held.
+func (s *Service) endBrokerThrottleLocked() {
+	if s.throttleTime.IsZero() {
+		return
+	}
+	logging.Printf("[OK] Broker is matching clients again after backing off for %s\n",
+		formatDuration(time.Since(s.throttleTime).Truncate(time.Second)))
+	s.throttleTime = time.Time{}
+	s.throttleBackoff = 0
+	if s.metrics != nil {
+		s.metrics.SetBrokerThrottle(false, 0)
+	}
+}
+
+// throttleJSONLocked describes the broker request to back off in effect, if
+// any. Must be called with lock held.
+func (s *Service) throttleJSONLocked() *ThrottleJSON {
+	if s.throttleTime.IsZero() {
+		return nil
+	}
+	return &ThrottleJSON{
+		Since:          s.throttleTime.Format(time.RFC3339),
+		BackoffSeconds: s.throttleBackoff.Seconds(),
+	}
+}
+
+// resetPeak starts a new peak clients window from the current count and
+// returns the previous peak (thread-safe, for the control socket)
+func (s *Service) resetPeak() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous := s.stats.PeakSinceReset
+	s.stats.PeakSinceReset = s.stats.ConnectedClients
+	s.stats.PeakResetTime = time.Now()
+	if s.metrics != nil {
+		s.metrics.SetPeakClients(s.stats.PeakClients, s.stats.PeakSinceReset)
+	}
+	return previous
+}
+
+// notifyReady tells systemd the service is up once it is live with the
+// broker. After a restart, systemd ignores the repeat.
+func (s *Service) notifyReady() {
+	if _, err := systemd.Notify("READY=1"); err != nil {
+		logging.Printf("[WARN] %v\n", err)
+	}
+}
+
+// publish sends an event to control socket subscribers, if enabled
+func (s *Service) publish(eventType string, data map[string]any) {
+	if s.control != nil {
+		s.control.Publish(eventType, data)
+	}
+}
+
+// setMustUpgrade records that the broker rejected this version of conduit.
+// The broker only says that an upgrade is needed, not which version it wants,
+// so the log names the in-proxy protocol this build speaks and points at the
+// operator notice, which is where upgrade requests are published.
+func (s *Service) setMustUpgrade() {
+	if s.mustUpgrade.Swap(true) {
+		return
+	}
+	if s.metrics != nil {
+		s.metrics.SetMustUpgrade(true)
+	}
+	s.publish(control.EventInstanceState, map[string]any{"state": "must-upgrade"})
+
+	logging.Printf("[ERROR] The Psiphon broker no longer accepts this version of conduit (in-proxy protocol v%d). Upgrade conduit to keep serving clients; the proxy is stopping.\n",
+		inproxy.LatestProtocolVersion)
+	s.mu.RLock()
+	n := s.operatorNotice
+	s.mu.RUnlock()
+	switch {
+	case n != nil && n.URL != "":
+		logging.Printf("[ERROR] Operator notice: %s (%s)\n", n.Message, n.URL)
+	case n != nil:
+		logging.Printf("[ERROR] Operator notice: %s\n", n.Message)
+	case s.config.NoticeURL == "":
+		logging.Printf("[INFO] Set --operator-notice-url to be told about upgrades before the broker requires them\n")
 	}
 }
 
@@ -559,11 +1018,9 @@ func (s *Service) shouldLogInproxyActivity(now time.Time) (bool, int, int, int)
 	return false, announcing, connecting, connected
 }
 
-// formatBytes formats bytes as a human-readable string
-func formatBytes(bytes int64) string {
+// FormatBytes formats bytes as a human-readable string with a binary unit
+func FormatBytes(bytes int64) string {
 	const unit = 1024
-	return fmt.Sprintf("%d B", bytes)
-
 	if bytes < unit {
 		return fmt.Sprintf("%d B", bytes)
 	}
@@ -585,13 +1042,31 @@ func (s *Service) writeStatsToFile(statsJSON StatsJSON) {
 		return
 	}
 
-	if err := os.WriteFile(s.config.StatsFile, data, 0644); err != nil {
+	start := time.Now()
+	err = s.writeFile(s.config.StatsFile, data)
+	if s.metrics != nil {
+		s.metrics.ObserveStatsWrite(time.Since(start), err)
+	}
+	if err != nil {
 		if s.config.Verbosity >= 1 {
 			logging.Printf("[ERROR] Failed to write stats file: %v\n", err)
 		}
 	}
 }
 
+// writeFile writes an output file (not key material) with the configured
+// file mode. The mode is set exactly, so the umask doesn't apply to it.
+func (s *Service) writeFile(path string, data []byte) error {
+	if s.config.FileMode == 0 {
+		return os.WriteFile(path, data, 0644)
+	}
+	if err := os.WriteFile(path, data, s.config.FileMode); err != nil {
+		return err
+	}
+	// WriteFile keeps the mode of an existing file and applies the umask
+	return os.Chmod(path, s.config.FileMode)
+}
+
 // formatDuration formats duration in a human-readable way
 func formatDuration(d time.Duration) string {
 	h := d / time.Hour
@@ -606,6 +1081,81 @@ func formatDuration(d time.Duration) string {
 	return fmt.Sprintf("%ds", s)
 }
 
+// reportDroppedClients logs and records the clients cut off by a shutdown
+func (s *Service) reportDroppedClients() {
+	dropped := int(s.droppedClients.Load())
+	if dropped == 0 {
+		return
+	}
+	logging.Printf("[INFO] Shutdown terminated %d active client session(s)\n", dropped)
+	if s.metrics != nil {
+		s.metrics.AddForceDroppedClients(dropped)
+	}
+}
+
+// RecordConfigReloadFailure records that a reload was rejected and the
+// current configuration was kept
+func (s *Service) RecordConfigReloadFailure() {
+	if s.metrics != nil {
+		s.metrics.IncConfigReloadFailures()
+	}
+}
+
+// SetReloadHandler sets the function the control socket reload command
+// calls. It must be called before Run.
+func (s *Service) SetReloadHandler(reload func() error) {
+	s.reload = reload
+}
+
+// requestReload handles the control socket reload command
+func (s *Service) requestReload() error {
+	if s.reload == nil {
+		return errors.New("reload is not available")
+	}
+	logging.Println("[INFO] Reload requested on the control socket")
+	return s.reload()
+}
+
+// isLive reports whether the service has gone live with the broker (thread-safe)
+func (s *Service) isLive() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.stats.IsLive
+}
+
+// startTime returns when the service was created (thread-safe)
+func (s *Service) startTime() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.stats.StartTime
+}
+
+// SetConfigEpoch sets which configuration this service runs: 1 for the one
+// the process started with, plus one for each reload or failover since. It
+// must be called before Run.
+func (s *Service) SetConfigEpoch(epoch int) {
+	s.configEpoch = epoch
+	if s.metrics != nil {
+		s.metrics.SetConfigEpoch(epoch)
+	}
+}
+
+// RecordUnhealthyRestart records that this service replaces one that was
+// restarted for not going live
+func (s *Service) RecordUnhealthyRestart() {
+	if s.metrics != nil {
+		s.metrics.IncUnhealthyRestarts()
+	}
+}
+
+// RecordConfigFailover records that this service runs on a failover psiphon
+// config because the previous one did not go live
+func (s *Service) RecordConfigFailover() {
+	if s.metrics != nil {
+		s.metrics.IncConfigFailovers()
+	}
+}
+
 // GetStats returns current statistics
 func (s *Service) GetStats() Stats {
 	s.mu.RLock()
@@ -613,8 +1163,9 @@ func (s *Service) GetStats() Stats {
 	return *s.stats
 }
 
-// runWithIdleMonitoring runs the controller with idle time monitoring.
-// Returns ErrIdleRestart if idle timeout is reached, nil if context is cancelled.
+// runWithIdleMonitoring runs the controller with idle time and health monitoring.
+// Returns ErrIdleRestart if idle timeout is reached, ErrUnhealthyRestart if the
+// service hasn't gone live within the unhealthy timeout, nil if context is cancelled.
 func (s *Service) runWithIdleMonitoring(ctx context.Context) error {
 	// Create a cancellable context for the controller
 	controllerCtx, cancelController := context.WithCancel(ctx)
@@ -643,13 +1194,30 @@ func (s *Service) runWithIdleMonitoring(ctx context.Context) error {
 
 		case <-controllerDone:
 			// Controller exited on its own
+			if s.mustUpgrade.Load() {
+				return ErrMustUpgrade
+			}
 			return nil
 
 		case <-ticker.C:
+			// A proxy that never gets an announcement through to the broker
+			// won't recover by waiting; rebuild it with fresh connections
+			if s.config.UnhealthyRestart > 0 && !s.isLive() && time.Since(s.startTime()) >= s.config.UnhealthyRestart {
+				logging.Printf("[WARN] Not live with the broker after %s, restarting...\n", formatDuration(s.config.UnhealthyRestart))
+				s.publish(control.EventInstanceState, map[string]any{"state": "unhealthy-restart"})
+				cancelController()
+				<-controllerDone
+				return ErrUnhealthyRestart
+			}
+
+			if s.config.IdleRestart == 0 {
+				continue
+			}
 			idleSeconds := s.getIdleSecondsFloat()
 			if idleSeconds >= s.config.IdleRestart.Seconds() {
-				fmt.Printf("\n[IDLE] No activity for %s, restarting to refresh connections...\n",
+				logging.Printf("[IDLE] No activity for %s, restarting to refresh connections...\n",
 					formatDuration(time.Duration(idleSeconds)*time.Second))
+				s.publish(control.EventInstanceState, map[string]any{"state": "idle-restart"})
 				cancelController()
 				<-controllerDone
 				return ErrIdleRestart
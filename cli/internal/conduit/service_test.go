@@ -0,0 +1,193 @@
+/*
+ * Copyright (c) 2026, Psiphon Inc.
+ * All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package conduit
+
+import (
+	"testing"
+	"time"
+)
+
+// The error notices below have the shape tunnel-core's proxyClients logs
+// for a failed announcement: inproxy.(*Proxy).proxyOneClient returns
+// errors traced with the function and line they passed through.
+
+func TestBrokerLimited(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     map[string]interface{}
+		expected time.Duration
+		limited  bool
+	}{
+		{
+			name: "limited",
+			data: map[string]interface{}{
+				"message": "proxy client failed",
+				"error":   "inproxy.(*Proxy).proxyOneClient#901: limited",
+				"delay":   "2m0s",
+				"jitter":  0.3,
+			},
+			expected: 2 * time.Minute,
+			limited:  true,
+		},
+		{
+			name: "limited_without_delay",
+			data: map[string]interface{}{
+				"message": "proxy client failed",
+				"error":   "inproxy.(*Proxy).proxyOneClient#901: limited",
+			},
+			limited: true,
+		},
+		{
+			name: "no_match",
+			data: map[string]interface{}{
+				"message": "proxy client failed",
+				"error":   "inproxy.(*Proxy).proxyOneClient#905: no match",
+				"delay":   "1s",
+			},
+		},
+		{
+			name: "must_upgrade",
+			data: map[string]interface{}{
+				"message": "proxy client failed",
+				"error":   "inproxy.(*Proxy).proxyOneClient#896: must upgrade",
+				"delay":   "1m0s",
+			},
+		},
+		{
+			// Only the final cause counts, not text earlier in the chain
+			name: "limited_in_message",
+			data: map[string]interface{}{
+				"message": "proxy client failed",
+				"error":   "inproxy.(*Proxy).proxyOneClient#998: limited: unexpected response status code 502",
+				"delay":   "1s",
+			},
+		},
+		{
+			name: "other_message",
+			data: map[string]interface{}{
+				"message": "announcement request",
+				"error":   "limited",
+				"delay":   "2m0s",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			delay, limited := brokerLimited(test.data)
+			if limited != test.limited || delay != test.expected {
+				t.Fatalf("brokerLimited = %v, %v, expected %v, %v", delay, limited, test.expected, test.limited)
+			}
+		})
+	}
+}
+
+func TestBrokerTimedOut(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     map[string]interface{}
+		expected bool
+	}{
+		{
+			name: "announce_timeout",
+			data: map[string]interface{}{
+				"message": "proxy client failed",
+				"error":   "inproxy.(*Proxy).proxyOneClient#858: inproxy.(*BrokerClient).ProxyAnnounce#122: inproxy.(*BrokerClient).roundTrip#346: inproxy.(*InitiatorSessions).RoundTrip#328: context deadline exceeded",
+				"delay":   "1s",
+			},
+			expected: true,
+		},
+		{
+			name: "answer_timeout",
+			data: map[string]interface{}{
+				"message": "proxy client failed",
+				"error":   "inproxy.(*Proxy).proxyOneClient#998: inproxy.(*BrokerClient).ProxyAnswer#195: inproxy.(*BrokerClient).roundTrip#346: context deadline exceeded",
+				"delay":   "1s",
+			},
+			expected: true,
+		},
+		{
+			// A broker error that is not a timeout
+			name: "broker_error",
+			data: map[string]interface{}{
+				"message": "proxy client failed",
+				"error":   "inproxy.(*Proxy).proxyOneClient#858: inproxy.(*BrokerClient).ProxyAnnounce#122: inproxy.(*BrokerClient).roundTrip#346: unexpected response status code 502 after 1.2s",
+				"delay":   "1s",
+			},
+		},
+		{
+			// Timing out on the WebRTC connection is the client's network, not the broker
+			name: "webrtc_timeout",
+			data: map[string]interface{}{
+				"message": "proxy client failed",
+				"error":   "inproxy.(*Proxy).proxyOneClient#973: inproxy.newWebRTCConnForAnswer#216: context deadline exceeded",
+				"delay":   "1s",
+			},
+		},
+		{
+			name: "limited",
+			data: map[string]interface{}{
+				"message": "proxy client failed",
+				"error":   "inproxy.(*Proxy).proxyOneClient#901: limited",
+				"delay":   "2m0s",
+			},
+		},
+		{
+			name: "other_message",
+			data: map[string]interface{}{
+				"message": "announcement request",
+				"error":   "inproxy.(*BrokerClient).roundTrip#346: context deadline exceeded",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := brokerTimedOut(test.data); got != test.expected {
+				t.Fatalf("brokerTimedOut = %v, expected %v", got, test.expected)
+			}
+		})
+	}
+}
+
+func TestUpdateLiveLocked(t *testing.T) {
+	s := &Service{stats: &Stats{}}
+	start := time.Now()
+
+	s.stats.Announcing = 1
+	if !s.updateLiveLocked(start) {
+		t.Fatal("expected the service to go live")
+	}
+	if !s.stats.LiveTime.Equal(start) {
+		t.Errorf("LiveTime = %v, expected %v", s.stats.LiveTime, start)
+	}
+
+	s.stats.ConnectedClients = 1
+	first := start.Add(5 * time.Second)
+	if s.updateLiveLocked(first) {
+		t.Error("expected no second transition to live")
+	}
+	if !s.stats.FirstClientTime.Equal(first) {
+		t.Errorf("FirstClientTime = %v, expected %v", s.stats.FirstClientTime, first)
+	}
+	if !s.stats.LiveTime.Equal(start) {
+		t.Errorf("LiveTime moved to %v", s.stats.LiveTime)
+	}
+}
@@ -0,0 +1,108 @@
+/*
+ * Copyright (c) 2026, Psiphon Inc.
+ * All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// cgroupRoot is where the cgroup hierarchy is mounted
+const cgroupRoot = "/sys/fs/cgroup"
+
+// cgroupV1Unlimited is the smallest cgroup v1 limit treated as no limit: an
+// unlimited v1 cgroup reports the largest page-aligned int64
+const cgroupV1Unlimited = 1 << 62
+
+// cgroupMemoryLimit returns the memory limit of this process's cgroup and
+// the cgroup version it was read from ("v1" or "v2"), or 0 if there is none
+func cgroupMemoryLimit() (int64, string) {
+	self, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return 0, ""
+	}
+	return readCgroupMemoryLimit(cgroupRoot, string(self))
+}
+
+// readCgroupMemoryLimit finds the memory cgroup in selfCgroup (the contents
+// of /proc/self/cgroup) and returns the lowest limit set on it or any of its
+// ancestors under root. A v1 memory controller takes precedence over the v2
+// hierarchy, as on hybrid hosts only one of them manages memory.
+func readCgroupMemoryLimit(root, selfCgroup string) (int64, string) {
+	var v2Path string
+	for _, line := range strings.Split(selfCgroup, "\n") {
+		// hierarchy-ID:controller-list:cgroup-path
+		fields := strings.SplitN(line, ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		if fields[0] == "0" && fields[1] == "" {
+			v2Path = fields[2]
+			continue
+		}
+		for _, controller := range strings.Split(fields[1], ",") {
+			if controller == "memory" {
+				return lowestCgroupLimit(filepath.Join(root, "memory"), fields[2], "memory.limit_in_bytes"), "v1"
+			}
+		}
+	}
+	if v2Path == "" {
+		return 0, ""
+	}
+	return lowestCgroupLimit(root, v2Path, "memory.max"), "v2"
+}
+
+// lowestCgroupLimit returns the lowest limit in file from the cgroup at path
+// up to the hierarchy's mount point, or 0 if none is set. Inside a container
+// without a cgroup namespace, path names the cgroup on the host and the
+// container's own cgroup is mounted at mount itself.
+func lowestCgroupLimit(mount, path, file string) int64 {
+	dir := filepath.Join(mount, path)
+	if _, err := os.Stat(dir); err != nil {
+		dir = mount
+	}
+
+	var lowest int64
+	for {
+		if limit := readCgroupLimitFile(filepath.Join(dir, file)); limit > 0 && (lowest == 0 || limit < lowest) {
+			lowest = limit
+		}
+		if dir == mount || !strings.HasPrefix(dir, mount) {
+			return lowest
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+// readCgroupLimitFile reads a memory limit file, returning 0 for a missing
+// file or no limit ("max" in v2, a near-maximal value in v1)
+func readCgroupLimitFile(path string) int64 {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	limit, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil || limit <= 0 || limit >= cgroupV1Unlimited {
+		return 0
+	}
+	return limit
+}
@@ -0,0 +1,72 @@
+/*
+ * Copyright (c) 2026, Psiphon Inc.
+ * All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadCgroupMemoryLimit(t *testing.T) {
+	files := map[string]string{
+		"memory.max":                          "max\n",
+		"system.slice/memory.max":             "1073741824\n",
+		"system.slice/conduit/memory.max":     "max\n",
+		"docker/memory.max":                   "536870912\n",
+		"memory/memory.limit_in_bytes":        "9223372036854771712\n",
+		"memory/kube/memory.limit_in_bytes":   "268435456\n",
+		"memory/kube/a/memory.limit_in_bytes": "9223372036854771712\n",
+	}
+	root := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name        string
+		selfCgroup  string
+		expected    int64
+		expectedVer string
+	}{
+		{name: "no_cgroup"},
+		{name: "v2_unlimited", selfCgroup: "0::/\n", expectedVer: "v2"},
+		{name: "v2_limit_on_parent", selfCgroup: "0::/system.slice/conduit\n", expected: 1 << 30, expectedVer: "v2"},
+		{name: "v2_own_limit", selfCgroup: "0::/docker\n", expected: 1 << 29, expectedVer: "v2"},
+		{name: "v2_host_path_in_container", selfCgroup: "0::/kubepods/pod1/abc\n", expectedVer: "v2"},
+		{name: "v1_limit_on_parent", selfCgroup: "4:cpu,cpuacct:/kube/a\n3:memory:/kube/a\n0::/\n", expected: 1 << 28, expectedVer: "v1"},
+		{name: "v1_unlimited", selfCgroup: "3:memory:/\n", expectedVer: "v1"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			limit, version := readCgroupMemoryLimit(root, test.selfCgroup)
+			if limit != test.expected || version != test.expectedVer {
+				t.Fatalf("limit = %d (%q), expected %d (%q)", limit, version, test.expected, test.expectedVer)
+			}
+		})
+	}
+}
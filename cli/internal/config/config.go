@@ -21,16 +21,29 @@
 package config
 
 import (
+	"crypto/ed25519"
 	"crypto/sha256"
 	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"maps"
+	"math"
+	"net"
+	"net/url"
 	"os"
 	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/Psiphon-Inc/conduit/cli/internal/crypto"
 	"github.com/Psiphon-Inc/conduit/cli/internal/logging"
+	"github.com/Psiphon-Inc/conduit/cli/internal/notice"
+	"github.com/Psiphon-Labs/psiphon-tunnel-core/psiphon/common/protocol"
 )
 
 // Default values for CLI usage
@@ -40,14 +53,54 @@ const (
 	MaxClientsLimit      = 1000
 	UnlimitedBandwidth   = -1.0 // Special value for no bandwidth limit
 
+	DefaultMemoryPressure = 0.9
+	DefaultFDPressure     = 0.9
+	DefaultAnnounceJitter = 0.5 // Matches the tunnel-core default
+
+	// DefaultInstanceName is how an instance without --instance-name is
+	// reported, and the index its seeded key is derived from
+	DefaultInstanceName = "inst-0"
+
+	// ExitMustUpgrade is the exit status of conduit start once the broker
+	// requires a newer version, so service managers can tell it from a
+	// failure worth restarting
+	ExitMustUpgrade = 3
+
+	// MemoryLimitAuto sizes the memory limit from the container's cgroup
+	// limit, leaving autoMemoryHeadroom of it for memory Go doesn't manage
+	MemoryLimitAuto    = "auto"
+	autoMemoryHeadroom = 0.1
+
 	// File names for persisted data
 	keyFileName = "conduit_key.json"
 )
 
+// Fingerprint modes select the TLS profile used for broker requests
+const (
+	FingerprintRandom     = "random"     // tunnel-core picks a profile per request (default)
+	FingerprintRoundRobin = "roundrobin" // a different profile on each service start
+	FingerprintFixed      = "fixed"      // one profile for the life of the key
+)
+
+// fingerprintProfiles are the TLS profiles the roundrobin and fixed modes
+// choose from: current browser parrots, all supporting TLS 1.3
+var fingerprintProfiles = []string{
+	protocol.TLS_PROFILE_CHROME_102,
+	protocol.TLS_PROFILE_CHROME_106,
+	protocol.TLS_PROFILE_CHROME_112_PSK,
+	protocol.TLS_PROFILE_CHROME_120,
+	protocol.TLS_PROFILE_CHROME_120_PQ,
+	protocol.TLS_PROFILE_FIREFOX_99,
+	protocol.TLS_PROFILE_FIREFOX_105,
+	protocol.TLS_PROFILE_SAFARI_16,
+	protocol.TLS_PROFILE_IOS_14,
+}
+
 // Options represents CLI options passed to LoadOrCreate
 type Options struct {
 	DataDir           string
 	PsiphonConfigPath string
+	PsiphonConfigData []byte // Config fetched from a remote store (takes precedence over PsiphonConfigPath)
 	UseEmbeddedConfig bool
 	MaxClients        int
 	BandwidthMbps     float64
@@ -57,7 +110,43 @@ type Options struct {
 	GeoEnabled        bool   // Enable geo tracking via tcpdump
 	MetricsAddr       string // Address for Prometheus metrics endpoint (empty = disabled)
 	IdleRestart       time.Duration
-	Compartment       string // Human-readable compartment name for private pairing
+	UnhealthyRestart  time.Duration
+	Compartment       string  // Human-readable compartment name for private pairing
+	ControlSocket     string  // Path to control unix socket (empty = disabled)
+	DNSServer         string  // DNS server IP[:port] to prefer over the system resolver (empty = system)
+	Ephemeral         bool    // Use a throwaway identity and data dir instead of DataDir
+	IdentitySeed      string  // Hex seed new keys are derived from, for tests only (empty = random)
+	MetricLabels      string  // Constant metric labels as key=value,... (empty = none)
+	FingerprintMode   string  // One of the Fingerprint* modes (empty = random)
+	MemoryLimit       string  // Soft memory limit in GOMEMLIMIT format, e.g. 512MiB (empty = none)
+	MemoryPressure    float64 // Fraction of MemoryLimit treated as pressure (0 = default)
+	MinClientMbps     float64 // Bandwidth floor per client in Mbps (0 = off)
+	Reregister        bool    // Reset the broker session when local addresses change
+	AnnounceJitter    float64 // Fraction the delay between broker announcements varies by
+	AnnounceJitterSet bool
+	ReadOnlyData      bool // Never write to DataDir; the key must already exist
+	StrictPerms       bool // Refuse to start if others can access DataDir or the key
+	FixPerms          bool // Restrict DataDir and key permissions that others can access
+	RelayDialTimeout  time.Duration
+	AnnounceBackoff   time.Duration
+	BrokerTimeout     time.Duration
+	MetricsPrivacy    bool   // Coarsen exported metrics
+	NoticeURL         string // URL of the signed operator notice (empty = disabled)
+	NoticeKey         string // Base64 Ed25519 public key that signs the notice
+	LeakWatchdog      bool   // Warn and dump goroutines when they outgrow the sessions
+	Canary            bool   // Tag metrics and status as a canary
+	InstanceName      string // Name for logs, metrics and status (empty = none)
+	FileMode          string // Octal permissions for the stats file and profiles (empty = 0644 less umask)
+	InfluxAddr        string // Where to push metrics in InfluxDB line protocol (empty = disabled)
+	InfluxInterval    time.Duration
+	RemoteWriteURL    string // Prometheus remote write endpoint to push metrics to (empty = disabled)
+	RemoteInterval    time.Duration
+	RemoteTokenFile   string  // File holding a bearer token for RemoteWriteURL (empty = none)
+	DashboardAddr     string  // Address for the HTML dashboard, health checks and metrics (empty = disabled)
+	FDPressure        float64 // Fraction of the open files limit treated as pressure (0 = default)
+
+	// Ephemeral identity to keep, e.g. across reloads (nil = generate one)
+	EphemeralKey *crypto.KeyPair
 }
 
 // Config represents the validated configuration for the Conduit service
@@ -69,12 +158,45 @@ type Config struct {
 	CompartmentID           string // Base64-encoded personal compartment ID for private pairing
 	DataDir                 string
 	PsiphonConfigPath       string
-	PsiphonConfigData       []byte // Embedded config data (if used)
+	PsiphonConfigData       []byte // Embedded or fetched config data (if used)
 	Verbosity               int    // 0=normal, 1+=verbose
 	StatsFile               string // Path to write stats JSON file (empty = disabled)
 	GeoEnabled              bool   // Enable geo tracking via tcpdump
 	MetricsAddr             string // Address for Prometheus metrics endpoint (empty = disabled)
 	IdleRestart             time.Duration
+	UnhealthyRestart        time.Duration
+	ControlSocket           string            // Path to control unix socket (empty = disabled)
+	DNSServer               string            // Normalized DNS server IP:port (empty = system resolver)
+	Ephemeral               bool              // Throwaway identity that was never saved
+	IdentitySeeded          bool              // New keys were derived from IdentitySeed, so they are predictable
+	ReadOnlyData            bool              // The configured data dir is read-only; DataDir is a temporary directory
+	TempDataDir             bool              // DataDir is a temporary directory to remove on exit
+	MetricLabels            map[string]string // Constant labels added to every metric
+	FingerprintMode         string            // Validated Fingerprint* mode
+	MemoryLimitBytes        int64             // Soft memory limit (0 = none)
+	MemoryPressure          float64           // Fraction of MemoryLimitBytes treated as pressure
+	ContainerMemoryBytes    int64             // Memory limit of the process's cgroup (0 = none found)
+	CgroupVersion           string            // Where ContainerMemoryBytes was read: "v1" or "v2"
+	MinClientBytesPerSecond int               // Bandwidth floor per client, already applied to MaxClients (0 = off)
+	ReregisterOnNetChange   bool              // Reset the broker session when local addresses change
+	AnnounceJitter          float64           // Fraction the delay between broker announcements varies by
+	RelayDialTimeout        time.Duration     // Timeout for dialing a client's Psiphon server (0 = tunnel-core default)
+	AnnounceMaxBackoff      time.Duration     // Longest back-off between failed or throttled announcements (0 = tunnel-core default)
+	BrokerRequestTimeout    time.Duration     // Wait for a broker response beyond the broker's own hold time (0 = tunnel-core default)
+	MetricsPrivacy          bool              // Merge small countries and round client counts in exported metrics
+	NoticeURL               string            // URL of the signed operator notice (empty = disabled)
+	NoticeKey               ed25519.PublicKey // Key the operator notice must be signed with
+	LeakWatchdog            bool              // Warn and dump goroutines when they outgrow the sessions
+	Canary                  bool              // Canary instance, compared against the rest of the fleet
+	InstanceName            string            // Validated instance name (empty = none)
+	FileMode                os.FileMode       // Exact permissions for written files (0 = 0644 less umask)
+	InfluxAddr              string            // udp://, http(s):// or file:// address for InfluxDB line protocol (empty = disabled)
+	InfluxInterval          time.Duration     // How often metrics are pushed to InfluxAddr
+	RemoteWriteURL          string            // Prometheus remote write endpoint (empty = disabled)
+	RemoteWriteInterval     time.Duration     // How often metrics are pushed to RemoteWriteURL
+	RemoteWriteToken        string            // Bearer token for RemoteWriteURL (empty = none)
+	DashboardAddr           string            // Address for the HTML dashboard, health checks and metrics (empty = disabled)
+	FDPressure              float64           // Fraction of the open files soft limit treated as pressure
 }
 
 // persistedKey represents the key data saved to disk
@@ -85,18 +207,72 @@ type persistedKey struct {
 
 // LoadOrCreate loads existing configuration or creates a new one with generated keys.
 func LoadOrCreate(opts Options) (*Config, error) {
-	// Ensure data directory exists
+	// Ensure data directory exists. Ephemeral runs never write to it.
 	if opts.DataDir == "" {
 		opts.DataDir = "./data"
 	}
-	if err := os.MkdirAll(opts.DataDir, 0700); err != nil {
-		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	if !opts.Ephemeral {
+		if err := os.MkdirAll(opts.DataDir, 0700); err != nil {
+			return nil, fmt.Errorf("failed to create data directory: %w", err)
+		}
+	}
+
+	if err := checkStatsFilePath(opts); err != nil {
+		return nil, err
+	}
+
+	// A data dir baked into a read-only image is fine as long as it already
+	// holds a key, so detect that rather than failing on the first write
+	var err error
+	readOnly := opts.ReadOnlyData
+	if !readOnly && !opts.Ephemeral && !isWritableDir(opts.DataDir) {
+		if _, _, err := LoadKey(opts.DataDir); err == nil {
+			readOnly = true
+		}
+	}
+	if readOnly {
+		if err := checkReadOnlyPaths(opts); err != nil {
+			return nil, err
+		}
+	}
+	if !opts.Ephemeral {
+		if err := checkDataDirPerms(opts.DataDir, opts.StrictPerms, opts.FixPerms, readOnly); err != nil {
+			return nil, err
+		}
 	}
 
-	// Try to load existing key, or generate new one
-	keyPair, privateKeyBase64, err := loadOrCreateKey(opts.DataDir, opts.Verbosity > 0)
+	// Try to load existing key, or generate new one. Ephemeral runs get a
+	// fresh key that is never written to disk, and read-only runs never
+	// create one.
+	identitySeed, err := parseIdentitySeed(opts.IdentitySeed)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load or create key: %w", err)
+		return nil, err
+	}
+	// An unnamed instance is inst-0, so it derives the same seeded key
+	keyIndex := opts.InstanceName
+	if keyIndex == "" {
+		keyIndex = DefaultInstanceName
+	}
+	var keyPair *crypto.KeyPair
+	var privateKeyBase64 string
+	if readOnly {
+		keyPair, privateKeyBase64, err = LoadKey(opts.DataDir)
+		if err != nil {
+			return nil, fmt.Errorf("read-only data directory has no usable key: %w", err)
+		}
+	} else if opts.Ephemeral && opts.EphemeralKey != nil {
+		keyPair = opts.EphemeralKey
+		privateKeyBase64 = base64.RawStdEncoding.EncodeToString(keyPair.PrivateKey)
+	} else if opts.Ephemeral {
+		keyPair, _, privateKeyBase64, err = generateKey(identitySeed, keyIndex)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create key: %w", err)
+		}
+	} else {
+		keyPair, privateKeyBase64, err = loadOrCreateKey(opts.DataDir, identitySeed, keyIndex, opts.Verbosity > 0)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load or create key: %w", err)
+		}
 	}
 
 	// Handle psiphon config source
@@ -105,6 +281,9 @@ func LoadOrCreate(opts Options) (*Config, error) {
 	if opts.UseEmbeddedConfig {
 		psiphonConfigData = GetEmbeddedPsiphonConfig()
 		psiphonConfigFileData = psiphonConfigData
+	} else if len(opts.PsiphonConfigData) > 0 {
+		psiphonConfigData = opts.PsiphonConfigData
+		psiphonConfigFileData = psiphonConfigData
 	} else if opts.PsiphonConfigPath != "" {
 		data, err := os.ReadFile(opts.PsiphonConfigPath)
 		if err != nil {
@@ -176,6 +355,138 @@ func LoadOrCreate(opts Options) (*Config, error) {
 		}
 	}
 
+	// Lower max clients so that a full proxy still gives each client at
+	// least the requested share of the bandwidth limit
+	var minClientBytesPerSecond int
+	if opts.MinClientMbps < 0 {
+		return nil, fmt.Errorf("min-per-client-bandwidth must not be negative")
+	}
+	if opts.MinClientMbps > 0 {
+		if bandwidthBytesPerSecond == 0 {
+			return nil, fmt.Errorf("min-per-client-bandwidth requires a bandwidth limit")
+		}
+		minClientBytesPerSecond = int(opts.MinClientMbps * 1000 * 1000 / 8)
+		if minClientBytesPerSecond < 1 {
+			return nil, fmt.Errorf("min-per-client-bandwidth %g Mbps is less than 1 byte per second", opts.MinClientMbps)
+		}
+		maxClients = capClientsForBandwidth(maxClients, bandwidthBytesPerSecond, minClientBytesPerSecond)
+		if maxClients < 1 {
+			return nil, fmt.Errorf("min-per-client-bandwidth %.1f Mbps is more than the bandwidth limit", opts.MinClientMbps)
+		}
+	}
+
+	dnsServer, err := parseDNSServer(opts.DNSServer)
+	if err != nil {
+		return nil, err
+	}
+
+	metricLabels, err := parseMetricLabels(opts.MetricLabels)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Canary {
+		if metricLabels == nil {
+			metricLabels = make(map[string]string)
+		}
+		metricLabels["canary"] = "true"
+	}
+	if opts.InstanceName != "" {
+		if !isValidInstanceName(opts.InstanceName) {
+			return nil, fmt.Errorf("invalid instance-name %q: use up to 64 letters, digits, '.', '_' and '-', starting with a letter or digit", opts.InstanceName)
+		}
+		if metricLabels == nil {
+			metricLabels = make(map[string]string)
+		}
+		metricLabels["name"] = opts.InstanceName
+	}
+
+	containerMemory, cgroupVersion := cgroupMemoryLimit()
+	var memoryLimit int64
+	if opts.MemoryLimit == MemoryLimitAuto {
+		if containerMemory == 0 {
+			return nil, fmt.Errorf("memory-limit auto needs a cgroup memory limit, and none was found")
+		}
+		memoryLimit = int64(float64(containerMemory) * (1 - autoMemoryHeadroom))
+	} else {
+		memoryLimit, err = parseMemoryLimit(opts.MemoryLimit)
+		if err != nil {
+			return nil, err
+		}
+	}
+	memoryPressure := opts.MemoryPressure
+	if memoryPressure == 0 {
+		memoryPressure = DefaultMemoryPressure
+	}
+	if memoryPressure <= 0 || memoryPressure > 1 {
+		return nil, fmt.Errorf("memory-pressure must be greater than 0 and at most 1")
+	}
+
+	fdPressure := opts.FDPressure
+	if fdPressure == 0 {
+		fdPressure = DefaultFDPressure
+	}
+	if fdPressure <= 0 || fdPressure > 1 {
+		return nil, fmt.Errorf("fd-pressure must be greater than 0 and at most 1")
+	}
+
+	announceJitter := DefaultAnnounceJitter
+	if opts.AnnounceJitterSet {
+		announceJitter = opts.AnnounceJitter
+	}
+	if announceJitter < 0 || announceJitter > 1 {
+		return nil, fmt.Errorf("announce-jitter must be between 0 and 1")
+	}
+
+	noticeKey, err := parseNoticeOptions(opts.NoticeURL, opts.NoticeKey)
+	if err != nil {
+		return nil, err
+	}
+
+	fileMode, err := parseFileMode(opts.FileMode)
+	if err != nil {
+		return nil, err
+	}
+	if err := prepareStatsFileDir(opts.StatsFile, fileMode); err != nil {
+		return nil, err
+	}
+
+	if err := checkInfluxAddr(opts.InfluxAddr); err != nil {
+		return nil, err
+	}
+	if opts.InfluxAddr != "" && opts.InfluxInterval < time.Second {
+		return nil, fmt.Errorf("influx-interval must be at least 1s")
+	}
+
+	if err := checkRemoteWriteURL(opts.RemoteWriteURL); err != nil {
+		return nil, err
+	}
+	if opts.RemoteWriteURL != "" && opts.RemoteInterval < time.Second {
+		return nil, fmt.Errorf("remote-write-interval must be at least 1s")
+	}
+	var remoteWriteToken string
+	if opts.RemoteTokenFile != "" {
+		if opts.RemoteWriteURL == "" {
+			return nil, fmt.Errorf("remote-write-token-file requires remote-write-url")
+		}
+		data, err := os.ReadFile(opts.RemoteTokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read remote write token: %w", err)
+		}
+		remoteWriteToken = strings.TrimSpace(string(data))
+		if remoteWriteToken == "" {
+			return nil, fmt.Errorf("remote write token file %s is empty", opts.RemoteTokenFile)
+		}
+	}
+
+	fingerprintMode := opts.FingerprintMode
+	switch fingerprintMode {
+	case "":
+		fingerprintMode = FingerprintRandom
+	case FingerprintRandom, FingerprintRoundRobin, FingerprintFixed:
+	default:
+		return nil, fmt.Errorf("fingerprint-mode must be one of %s, %s or %s", FingerprintRandom, FingerprintRoundRobin, FingerprintFixed)
+	}
+
 	// Derive compartment ID from human-readable name using SHA-256
 	var compartmentID string
 	if opts.Compartment != "" {
@@ -183,13 +494,24 @@ func LoadOrCreate(opts Options) (*Config, error) {
 		compartmentID = base64.RawStdEncoding.EncodeToString(hash[:])
 	}
 
+	// Ephemeral and read-only runs keep the Psiphon data store out of
+	// DataDir too; the caller removes the directory on exit
+	dataDir := opts.DataDir
+	tempDataDir := opts.Ephemeral || readOnly
+	if tempDataDir {
+		dataDir, err = os.MkdirTemp("", "conduit-data-")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create temporary data directory: %w", err)
+		}
+	}
+
 	return &Config{
 		KeyPair:                 keyPair,
 		PrivateKeyBase64:        privateKeyBase64,
 		MaxClients:              maxClients,
 		BandwidthBytesPerSecond: bandwidthBytesPerSecond,
 		CompartmentID:           compartmentID,
-		DataDir:                 opts.DataDir,
+		DataDir:                 dataDir,
 		PsiphonConfigPath:       opts.PsiphonConfigPath,
 		PsiphonConfigData:       psiphonConfigData,
 		Verbosity:               opts.Verbosity,
@@ -197,11 +519,504 @@ func LoadOrCreate(opts Options) (*Config, error) {
 		GeoEnabled:              opts.GeoEnabled,
 		MetricsAddr:             opts.MetricsAddr,
 		IdleRestart:             opts.IdleRestart,
+		UnhealthyRestart:        opts.UnhealthyRestart,
+		ControlSocket:           opts.ControlSocket,
+		DNSServer:               dnsServer,
+		Ephemeral:               opts.Ephemeral,
+		IdentitySeeded:          identitySeed != nil,
+		ReadOnlyData:            readOnly,
+		TempDataDir:             tempDataDir,
+		RelayDialTimeout:        opts.RelayDialTimeout,
+		AnnounceMaxBackoff:      opts.AnnounceBackoff,
+		BrokerRequestTimeout:    opts.BrokerTimeout,
+		MetricsPrivacy:          opts.MetricsPrivacy,
+		NoticeURL:               opts.NoticeURL,
+		NoticeKey:               noticeKey,
+		LeakWatchdog:            opts.LeakWatchdog,
+		Canary:                  opts.Canary,
+		InstanceName:            opts.InstanceName,
+		FileMode:                fileMode,
+		InfluxAddr:              opts.InfluxAddr,
+		InfluxInterval:          opts.InfluxInterval,
+		RemoteWriteURL:          opts.RemoteWriteURL,
+		RemoteWriteInterval:     opts.RemoteInterval,
+		RemoteWriteToken:        remoteWriteToken,
+		DashboardAddr:           opts.DashboardAddr,
+		FDPressure:              fdPressure,
+		MetricLabels:            metricLabels,
+		FingerprintMode:         fingerprintMode,
+		MemoryLimitBytes:        memoryLimit,
+		MemoryPressure:          memoryPressure,
+		ContainerMemoryBytes:    containerMemory,
+		CgroupVersion:           cgroupVersion,
+		MinClientBytesPerSecond: minClientBytesPerSecond,
+		ReregisterOnNetChange:   opts.Reregister,
+		AnnounceJitter:          announceJitter,
 	}, nil
 }
 
-// loadOrCreateKey loads an existing key from disk or generates a new one
-func loadOrCreateKey(dataDir string, verbose bool) (*crypto.KeyPair, string, error) {
+// parseNoticeOptions validates the operator notice URL and returns the
+// pinned key it must be signed with
+func parseNoticeOptions(noticeURL, key string) (ed25519.PublicKey, error) {
+	if noticeURL == "" {
+		if key != "" {
+			return nil, fmt.Errorf("operator-notice-key requires operator-notice-url")
+		}
+		return nil, nil
+	}
+	u, err := url.Parse(noticeURL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return nil, fmt.Errorf("operator-notice-url must be an http or https URL")
+	}
+	if key == "" {
+		return nil, fmt.Errorf("operator-notice-url requires operator-notice-key")
+	}
+	return notice.ParseKey(key)
+}
+
+// checkInfluxAddr validates an InfluxDB line protocol address: udp://host:port,
+// an http(s) write URL, or file:// with an absolute path
+func checkInfluxAddr(addr string) error {
+	if addr == "" {
+		return nil
+	}
+	u, err := url.Parse(addr)
+	if err != nil {
+		return fmt.Errorf("invalid influx-addr: %w", err)
+	}
+	switch u.Scheme {
+	case "udp":
+		if _, port, err := net.SplitHostPort(u.Host); err != nil || port == "" {
+			return fmt.Errorf("influx-addr udp://host:port needs a port")
+		}
+	case "http", "https":
+		if u.Host == "" {
+			return fmt.Errorf("influx-addr %s URL needs a host", u.Scheme)
+		}
+	case "file":
+		if u.Host != "" || !filepath.IsAbs(u.Path) {
+			return fmt.Errorf("influx-addr file URL needs an absolute path, e.g. file:///var/log/conduit.lp")
+		}
+	default:
+		return fmt.Errorf("influx-addr must start with udp://, http://, https:// or file://")
+	}
+	return nil
+}
+
+// checkRemoteWriteURL validates a Prometheus remote write endpoint, which
+// must be an http(s) URL with a host
+func checkRemoteWriteURL(addr string) error {
+	if addr == "" {
+		return nil
+	}
+	u, err := url.Parse(addr)
+	if err != nil {
+		return fmt.Errorf("invalid remote-write-url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("remote-write-url must start with http:// or https://")
+	}
+	if u.Host == "" {
+		return fmt.Errorf("remote-write-url needs a host")
+	}
+	return nil
+}
+
+// isWritableDir reports whether files can be created in dir
+func isWritableDir(dir string) bool {
+	f, err := os.CreateTemp(dir, ".conduit-write-test-")
+	if err != nil {
+		return false
+	}
+	_ = f.Close()
+	_ = os.Remove(f.Name())
+	return true
+}
+
+// prepareStatsFileDir creates the stats file's directory if it is missing,
+// readable by whoever fileMode lets read the file, and checks that it is
+// writable, so a bad path fails at startup rather than on every write
+func prepareStatsFileDir(statsFile string, fileMode os.FileMode) error {
+	if statsFile == "" {
+		return nil
+	}
+	dirMode := os.FileMode(0755)
+	if fileMode != 0 {
+		// Searching a directory needs execute wherever the file is readable
+		dirMode = fileMode | (fileMode&0444)>>2
+	}
+	dir := filepath.Dir(statsFile)
+	if err := os.MkdirAll(dir, dirMode); err != nil {
+		return fmt.Errorf("failed to create stats file directory: %w", err)
+	}
+	if !isWritableDir(dir) {
+		return fmt.Errorf("stats file directory %s is not writable", dir)
+	}
+	return nil
+}
+
+// checkReadOnlyPaths rejects outputs that would be written into a read-only
+// data directory
+func checkReadOnlyPaths(opts Options) error {
+	if opts.Ephemeral {
+		return fmt.Errorf("read-only-data and ephemeral cannot be used together")
+	}
+
+	outputs := []struct {
+		flag string
+		path string
+	}{
+		{"stats-file", opts.StatsFile},
+		{"control-socket", opts.ControlSocket},
+	}
+	for _, output := range outputs {
+		if output.path != "" && isWithin(opts.DataDir, output.path) {
+			return fmt.Errorf("%s %s is in the read-only data directory; give --%s a writable path", output.flag, output.path, output.flag)
+		}
+	}
+
+	return nil
+}
+
+// isWithin reports whether path is dir or inside it
+func isWithin(dir, path string) bool {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// capClientsForBandwidth returns the largest client count, up to maxClients,
+// at which each client's share of bandwidthBytesPerSecond is at least
+// minClientBytesPerSecond
+func capClientsForBandwidth(maxClients, bandwidthBytesPerSecond, minClientBytesPerSecond int) int {
+	return min(maxClients, bandwidthBytesPerSecond/minClientBytesPerSecond)
+}
+
+// checkStatsFilePath rejects a stats file that resolves to another file the
+// service reads or writes, which would otherwise be silently overwritten
+func checkStatsFilePath(opts Options) error {
+	if opts.StatsFile == "" {
+		return nil
+	}
+
+	statsFile, err := filepath.Abs(opts.StatsFile)
+	if err != nil {
+		return fmt.Errorf("invalid stats file path: %w", err)
+	}
+
+	others := []struct {
+		name string
+		path string
+	}{
+		{"key file", filepath.Join(opts.DataDir, keyFileName)},
+		{"control socket", opts.ControlSocket},
+		{"psiphon config", opts.PsiphonConfigPath},
+		{"data directory", opts.DataDir},
+	}
+	for _, other := range others {
+		if other.path == "" {
+			continue
+		}
+		path, err := filepath.Abs(other.path)
+		if err != nil {
+			continue
+		}
+		if path == statsFile {
+			return fmt.Errorf("stats file %s is the same path as the %s", opts.StatsFile, other.name)
+		}
+	}
+
+	return nil
+}
+
+// parseDNSServer validates a DNS server given as an IP address with an
+// optional port and returns it in IP:port form. The Psiphon resolver only
+// speaks plain DNS over UDP, so hostnames and DoH URLs are rejected.
+func parseDNSServer(server string) (string, error) {
+	if server == "" {
+		return "", nil
+	}
+	if strings.Contains(server, "://") {
+		return "", fmt.Errorf("invalid dns-server %q: only plain DNS is supported (use IP or IP:port)", server)
+	}
+
+	host, port, err := net.SplitHostPort(server)
+	if err != nil {
+		// No port given; strip brackets from a bare IPv6 literal
+		host = strings.TrimSuffix(strings.TrimPrefix(server, "["), "]")
+		port = "53"
+	}
+	if net.ParseIP(host) == nil {
+		return "", fmt.Errorf("invalid dns-server %q: must be an IP address, optionally with a port", server)
+	}
+	if n, err := net.LookupPort("udp", port); err != nil || n < 1 {
+		return "", fmt.Errorf("invalid dns-server port %q", port)
+	}
+
+	return net.JoinHostPort(host, port), nil
+}
+
+// TLSProfile returns the TLS profile to pin broker requests to, or "" to
+// let tunnel-core choose. The fixed mode derives the profile from the public
+// key, so it is stable across restarts but differs between instances;
+// roundrobin starts from the same profile and advances by start, the number
+// of times the service has been started in this process.
+func (c *Config) TLSProfile(start int) string {
+	if c.FingerprintMode == FingerprintRandom || c.FingerprintMode == "" || c.KeyPair == nil {
+		return ""
+	}
+
+	hash := sha256.Sum256(c.KeyPair.PublicKey)
+	index := int(binary.BigEndian.Uint32(hash[:4]) % uint32(len(fingerprintProfiles)))
+	if c.FingerprintMode == FingerprintRoundRobin {
+		index = (index + start) % len(fingerprintProfiles)
+	}
+	return fingerprintProfiles[index]
+}
+
+// parseMemoryLimit parses a byte count in the GOMEMLIMIT format: an integer
+// with an optional B, KiB, MiB, GiB or TiB suffix
+func parseMemoryLimit(limit string) (int64, error) {
+	if limit == "" {
+		return 0, nil
+	}
+
+	number := strings.TrimRight(limit, "BKMGTi")
+	multiplier := int64(1)
+	switch strings.TrimPrefix(limit, number) {
+	case "", "B":
+	case "KiB":
+		multiplier = 1 << 10
+	case "MiB":
+		multiplier = 1 << 20
+	case "GiB":
+		multiplier = 1 << 30
+	case "TiB":
+		multiplier = 1 << 40
+	default:
+		return 0, fmt.Errorf("invalid memory-limit %q: use a size like 512MiB or 2GiB", limit)
+	}
+
+	n, err := strconv.ParseInt(number, 10, 64)
+	if err != nil || n <= 0 || n > math.MaxInt64/multiplier {
+		return 0, fmt.Errorf("invalid memory-limit %q: use a size like 512MiB or 2GiB", limit)
+	}
+	return n * multiplier, nil
+}
+
+// reservedMetricLabels are label names already used by Conduit's metrics or
+// attached by Prometheus at scrape time
+var reservedMetricLabels = map[string]bool{
+	"instance":     true,
+	"job":          true,
+	"country_code": true,
+	"build_repo":   true,
+	"build_rev":    true,
+	"go_version":   true,
+	"values_rev":   true,
+	"canary":       true, // Set by --canary
+	"name":         true, // Set by --instance-name
+}
+
+// parseMetricLabels parses a comma-separated list of key=value pairs into
+// constant metric labels
+func parseMetricLabels(labels string) (map[string]string, error) {
+	if labels == "" {
+		return nil, nil
+	}
+
+	parsed := make(map[string]string)
+	for _, pair := range strings.Split(labels, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || value == "" {
+			return nil, fmt.Errorf("invalid metric label %q: use key=value", pair)
+		}
+		if !isValidLabelName(name) {
+			return nil, fmt.Errorf("invalid metric label name %q: use letters, digits and underscores, not starting with a digit or __", name)
+		}
+		if reservedMetricLabels[name] {
+			return nil, fmt.Errorf("metric label name %q is reserved", name)
+		}
+		if _, exists := parsed[name]; exists {
+			return nil, fmt.Errorf("metric label %q given more than once", name)
+		}
+		parsed[name] = value
+	}
+
+	return parsed, nil
+}
+
+// isValidLabelName reports whether name is a valid, non-reserved Prometheus label name
+func isValidLabelName(name string) bool {
+	if name == "" || strings.HasPrefix(name, "__") {
+		return false
+	}
+	for i, c := range name {
+		isLetter := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c == '_'
+		isDigit := c >= '0' && c <= '9'
+		if !isLetter && !(isDigit && i > 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseFileMode parses an octal permission mode such as 0640 (empty = 0)
+func parseFileMode(mode string) (os.FileMode, error) {
+	if mode == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil || n > 0777 {
+		return 0, fmt.Errorf("invalid file-mode %q: use octal permissions like 0640", mode)
+	}
+	if n&0600 != 0600 {
+		return 0, fmt.Errorf("file-mode %q must let the owner read and write", mode)
+	}
+	return os.FileMode(n), nil
+}
+
+// isValidInstanceName reports whether name is safe to use as a metric label
+// value, in logs and in file names
+func isValidInstanceName(name string) bool {
+	if name == "" || len(name) > 64 {
+		return false
+	}
+	for i, c := range name {
+		isAlnum := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+		if !isAlnum && (i == 0 || (c != '.' && c != '_' && c != '-')) {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidatePsiphonConfig checks that data is a Psiphon config object carrying
+// the network identifiers the broker requires. It does not contact the network.
+func ValidatePsiphonConfig(data []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return fmt.Errorf("failed to parse psiphon config file: %w", err)
+	}
+
+	for _, key := range []string{"PropagationChannelId", "SponsorId"} {
+		raw, ok := fields[key]
+		if !ok {
+			return fmt.Errorf("invalid psiphon config: missing %s", key)
+		}
+		var value string
+		if err := json.Unmarshal(raw, &value); err != nil || value == "" {
+			return fmt.Errorf("invalid psiphon config: %s must be a non-empty string", key)
+		}
+	}
+
+	return nil
+}
+
+// ConfigInfo identifies a Psiphon config without exposing its secrets
+type ConfigInfo struct {
+	Fingerprint          string // SHA-256 of the raw config bytes, hex-encoded
+	PropagationChannelID string
+	SponsorID            string
+}
+
+// DescribePsiphonConfig returns the identifying, non-secret fields of a Psiphon config
+func DescribePsiphonConfig(data []byte) (*ConfigInfo, error) {
+	var ids struct {
+		PropagationChannelId string `json:"PropagationChannelId"`
+		SponsorId            string `json:"SponsorId"`
+	}
+	if err := json.Unmarshal(data, &ids); err != nil {
+		return nil, fmt.Errorf("failed to parse psiphon config: %w", err)
+	}
+
+	hash := sha256.Sum256(data)
+	return &ConfigInfo{
+		Fingerprint:          hex.EncodeToString(hash[:]),
+		PropagationChannelID: ids.PropagationChannelId,
+		SponsorID:            ids.SponsorId,
+	}, nil
+}
+
+// EmbeddedConfigInfo describes the config embedded at build time, or returns
+// nil if the binary was built without one
+func EmbeddedConfigInfo() (*ConfigInfo, error) {
+	if !HasEmbeddedConfig() {
+		return nil, nil
+	}
+	return DescribePsiphonConfig(GetEmbeddedPsiphonConfig())
+}
+
+// Kinds of ConfigDifference
+const (
+	ConfigKeyChanged = "changed"
+	ConfigKeyAdded   = "added"   // Only in the compared config
+	ConfigKeyMissing = "missing" // Only in the base config
+)
+
+// ConfigDifference is a key whose value differs between two Psiphon configs.
+// Values are left out because they may be secret.
+type ConfigDifference struct {
+	Key  string // Dotted path for keys inside nested objects
+	Kind string // One of the ConfigKey* kinds
+}
+
+// DiffPsiphonConfigs compares two Psiphon configs key by key, ignoring JSON
+// formatting and key order, and returns the differing keys in sorted order
+func DiffPsiphonConfigs(base, other []byte) ([]ConfigDifference, error) {
+	var baseJSON, otherJSON map[string]any
+	if err := json.Unmarshal(base, &baseJSON); err != nil {
+		return nil, fmt.Errorf("failed to parse psiphon config: %w", err)
+	}
+	if err := json.Unmarshal(other, &otherJSON); err != nil {
+		return nil, fmt.Errorf("failed to parse psiphon config: %w", err)
+	}
+	return diffObjects("", baseJSON, otherJSON), nil
+}
+
+// diffObjects appends the differences between two JSON objects, descending
+// into objects present in both
+func diffObjects(prefix string, base, other map[string]any) []ConfigDifference {
+	var diffs []ConfigDifference
+	keys := slices.Collect(maps.Keys(base))
+	for key := range other {
+		if _, ok := base[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+
+	for _, key := range keys {
+		baseValue, inBase := base[key]
+		otherValue, inOther := other[key]
+		switch {
+		case !inOther:
+			diffs = append(diffs, ConfigDifference{Key: prefix + key, Kind: ConfigKeyMissing})
+		case !inBase:
+			diffs = append(diffs, ConfigDifference{Key: prefix + key, Kind: ConfigKeyAdded})
+		default:
+			baseObject, baseIsObject := baseValue.(map[string]any)
+			otherObject, otherIsObject := otherValue.(map[string]any)
+			if baseIsObject && otherIsObject {
+				diffs = append(diffs, diffObjects(prefix+key+".", baseObject, otherObject)...)
+			} else if !reflect.DeepEqual(baseValue, otherValue) {
+				diffs = append(diffs, ConfigDifference{Key: prefix + key, Kind: ConfigKeyChanged})
+			}
+		}
+	}
+	return diffs
+}
+
+// loadOrCreateKey loads an existing key from disk or generates a new one,
+// derived from seed and index if seed is set
+func loadOrCreateKey(dataDir string, seed []byte, index string, verbose bool) (*crypto.KeyPair, string, error) {
 	keyPath := filepath.Join(dataDir, keyFileName)
 
 	// Try to load existing key
@@ -226,21 +1041,11 @@ func loadOrCreateKey(dataDir string, verbose bool) (*crypto.KeyPair, string, err
 	}
 
 	// Generate new key
-
-	// Generate mnemonic for backup purposes
-	mnemonic, err := crypto.GenerateMnemonic()
+	keyPair, mnemonic, privateKeyBase64, err := generateKey(seed, index)
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to generate mnemonic: %w", err)
+		return nil, "", err
 	}
 
-	// Derive key from mnemonic
-	keyPair, err := crypto.DeriveKeyPairFromMnemonic(mnemonic, "")
-	if err != nil {
-		return nil, "", fmt.Errorf("failed to derive key: %w", err)
-	}
-
-	privateKeyBase64 := base64.RawStdEncoding.EncodeToString(keyPair.PrivateKey)
-
 	// Save to disk
 	pk := persistedKey{
 		Mnemonic:         mnemonic,
@@ -262,9 +1067,58 @@ func loadOrCreateKey(dataDir string, verbose bool) (*crypto.KeyPair, string, err
 	return keyPair, privateKeyBase64, nil
 }
 
+// generateKey creates a new key along with the mnemonic it was derived from.
+// The mnemonic is random unless seed is set, in which case it is derived
+// from seed and index.
+func generateKey(seed []byte, index string) (*crypto.KeyPair, string, string, error) {
+	// Generate mnemonic for backup purposes
+	var mnemonic string
+	var err error
+	if seed != nil {
+		mnemonic, err = crypto.MnemonicFromSeed(seed, index)
+	} else {
+		mnemonic, err = crypto.GenerateMnemonic()
+	}
+	if err != nil {
+		return nil, "", "", fmt.Errorf("failed to generate mnemonic: %w", err)
+	}
+
+	// Derive key from mnemonic
+	keyPair, err := crypto.DeriveKeyPairFromMnemonic(mnemonic, "")
+	if err != nil {
+		return nil, "", "", fmt.Errorf("failed to derive key: %w", err)
+	}
+
+	privateKeyBase64 := base64.RawStdEncoding.EncodeToString(keyPair.PrivateKey)
+	return keyPair, mnemonic, privateKeyBase64, nil
+}
+
+// minIdentitySeedBytes keeps a test seed from being trivially guessable
+const minIdentitySeedBytes = 16
+
+// parseIdentitySeed decodes a hex --identity-seed (empty = nil, random keys)
+func parseIdentitySeed(s string) ([]byte, error) {
+	if s == "" {
+		return nil, nil
+	}
+	seed, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid identity-seed: must be hex: %w", err)
+	}
+	if len(seed) < minIdentitySeedBytes {
+		return nil, fmt.Errorf("identity-seed must be at least %d bytes (%d hex digits)", minIdentitySeedBytes, 2*minIdentitySeedBytes)
+	}
+	return seed, nil
+}
+
+// KeyPath returns the path of the key file in dataDir
+func KeyPath(dataDir string) string {
+	return filepath.Join(dataDir, keyFileName)
+}
+
 // LoadKey loads an existing key from disk (for claim command)
 func LoadKey(dataDir string) (*crypto.KeyPair, string, error) {
-	keyPath := filepath.Join(dataDir, keyFileName)
+	keyPath := KeyPath(dataDir)
 
 	// Try to load existing key
 	data, err := os.ReadFile(keyPath)
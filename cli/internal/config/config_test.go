@@ -3,6 +3,8 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -85,3 +87,567 @@ func TestLoadOrCreatePrecedence(t *testing.T) {
 		})
 	}
 }
+
+func TestParseDNSServer(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		wantErr  bool
+	}{
+		{input: "", expected: ""},
+		{input: "1.1.1.1", expected: "1.1.1.1:53"},
+		{input: "9.9.9.9:5353", expected: "9.9.9.9:5353"},
+		{input: "2606:4700:4700::1111", expected: "[2606:4700:4700::1111]:53"},
+		{input: "[2606:4700:4700::1111]:53", expected: "[2606:4700:4700::1111]:53"},
+		{input: "dns.google", wantErr: true},
+		{input: "https://dns.google/dns-query", wantErr: true},
+		{input: "1.1.1.1:0", wantErr: true},
+		{input: "1.1.1.1:99999", wantErr: true},
+	}
+
+	for _, test := range tests {
+		got, err := parseDNSServer(test.input)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("parseDNSServer(%q) = %q, expected error", test.input, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseDNSServer(%q): %v", test.input, err)
+			continue
+		}
+		if got != test.expected {
+			t.Errorf("parseDNSServer(%q) = %q, expected %q", test.input, got, test.expected)
+		}
+	}
+}
+
+func TestCapClientsForBandwidth(t *testing.T) {
+	tests := []struct {
+		maxClients int
+		bandwidth  int
+		floor      int
+		expected   int
+	}{
+		{maxClients: 50, bandwidth: bandwidthBytes(40), floor: bandwidthBytes(2), expected: 20},
+		{maxClients: 10, bandwidth: bandwidthBytes(40), floor: bandwidthBytes(2), expected: 10},
+		{maxClients: 50, bandwidth: bandwidthBytes(40), floor: bandwidthBytes(3), expected: 13},
+		{maxClients: 50, bandwidth: bandwidthBytes(1), floor: bandwidthBytes(2), expected: 0},
+	}
+
+	for _, test := range tests {
+		got := capClientsForBandwidth(test.maxClients, test.bandwidth, test.floor)
+		if got != test.expected {
+			t.Errorf("capClientsForBandwidth(%d, %d, %d) = %d, expected %d", test.maxClients, test.bandwidth, test.floor, got, test.expected)
+		}
+	}
+}
+
+func TestLoadOrCreateMinClientBandwidth(t *testing.T) {
+	dataDir := t.TempDir()
+	configPath := writeTempConfig(t, dataDir, `{}`)
+	load := func(minClientMbps float64) (*Config, error) {
+		return LoadOrCreate(Options{
+			DataDir:           dataDir,
+			PsiphonConfigPath: configPath,
+			MaxClients:        50,
+			BandwidthMbps:     40,
+			MinClientMbps:     minClientMbps,
+		})
+	}
+
+	cfg, err := load(2)
+	if err != nil {
+		t.Fatalf("LoadOrCreate: %v", err)
+	}
+	if cfg.MaxClients != 20 || cfg.MinClientBytesPerSecond != bandwidthBytes(2) {
+		t.Errorf("MaxClients = %d, MinClientBytesPerSecond = %d, expected 20 and %d", cfg.MaxClients, cfg.MinClientBytesPerSecond, bandwidthBytes(2))
+	}
+
+	// Rounds down to 0 bytes per second, which can't cap anything
+	if _, err := load(0.000001); err == nil {
+		t.Errorf("expected error for a floor under 1 byte per second")
+	}
+}
+
+func TestCheckStatsFilePath(t *testing.T) {
+	dataDir := t.TempDir()
+	tests := []struct {
+		name    string
+		opts    Options
+		wantErr bool
+	}{
+		{name: "disabled", opts: Options{DataDir: dataDir}},
+		{name: "distinct", opts: Options{DataDir: dataDir, StatsFile: filepath.Join(dataDir, "stats.json"), ControlSocket: filepath.Join(dataDir, "conduit.sock")}},
+		{name: "key file", opts: Options{DataDir: dataDir, StatsFile: filepath.Join(dataDir, keyFileName)}, wantErr: true},
+		{name: "unclean key file", opts: Options{DataDir: dataDir, StatsFile: filepath.Join(dataDir, "sub", "..", keyFileName)}, wantErr: true},
+		{name: "control socket", opts: Options{DataDir: dataDir, StatsFile: filepath.Join(dataDir, "conduit.sock"), ControlSocket: filepath.Join(dataDir, "conduit.sock")}, wantErr: true},
+		{name: "psiphon config", opts: Options{DataDir: dataDir, StatsFile: "psiphon_config.json", PsiphonConfigPath: "./psiphon_config.json"}, wantErr: true},
+		{name: "data directory", opts: Options{DataDir: dataDir, StatsFile: dataDir + "/"}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		err := checkStatsFilePath(test.opts)
+		if test.wantErr && err == nil {
+			t.Errorf("%s: expected error", test.name)
+		}
+		if !test.wantErr && err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		}
+	}
+}
+
+func TestPrepareStatsFileDir(t *testing.T) {
+	root := t.TempDir()
+
+	nested := filepath.Join(root, "a", "b", "stats.json")
+	if err := prepareStatsFileDir(nested, 0640); err != nil {
+		t.Fatalf("prepareStatsFileDir: %v", err)
+	}
+	info, err := os.Stat(filepath.Dir(nested))
+	if err != nil || !info.IsDir() {
+		t.Fatalf("stats file directory not created: %v", err)
+	}
+	if extra := info.Mode().Perm() &^ 0750; extra != 0 {
+		t.Errorf("directory mode %v grants more than 0750 for file mode 0640", info.Mode().Perm())
+	}
+	if err := os.WriteFile(nested, []byte("{}"), 0640); err != nil {
+		t.Fatalf("stats file not writable in the created directory: %v", err)
+	}
+
+	// An existing directory is fine, a file in the way is not
+	if err := prepareStatsFileDir(filepath.Join(root, "stats.json"), 0); err != nil {
+		t.Errorf("existing directory: %v", err)
+	}
+	if err := prepareStatsFileDir(filepath.Join(nested, "stats.json"), 0); err == nil {
+		t.Errorf("expected error when the directory is a file")
+	}
+}
+
+func TestParseMetricLabels(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected map[string]string
+		wantErr  bool
+	}{
+		{input: "", expected: nil},
+		{input: "region=eu", expected: map[string]string{"region": "eu"}},
+		{input: "deployment=fleet-a, region=eu", expected: map[string]string{"deployment": "fleet-a", "region": "eu"}},
+		{input: "region", wantErr: true},
+		{input: "region=", wantErr: true},
+		{input: "1region=eu", wantErr: true},
+		{input: "__name__=x", wantErr: true},
+		{input: "re-gion=eu", wantErr: true},
+		{input: "instance=a", wantErr: true},
+		{input: "country_code=us", wantErr: true},
+		{input: "region=eu,region=us", wantErr: true},
+	}
+
+	for _, test := range tests {
+		got, err := parseMetricLabels(test.input)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("parseMetricLabels(%q) = %v, expected error", test.input, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseMetricLabels(%q): %v", test.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("parseMetricLabels(%q) = %v, expected %v", test.input, got, test.expected)
+		}
+	}
+}
+
+func TestParseMemoryLimit(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+		wantErr  bool
+	}{
+		{input: "", expected: 0},
+		{input: "1048576", expected: 1 << 20},
+		{input: "4096B", expected: 4096},
+		{input: "512MiB", expected: 512 << 20},
+		{input: "2GiB", expected: 2 << 30},
+		{input: "512MB", wantErr: true},
+		{input: "1.5GiB", wantErr: true},
+		{input: "0MiB", wantErr: true},
+		{input: "-1", wantErr: true},
+		{input: "99999999TiB", wantErr: true},
+	}
+
+	for _, test := range tests {
+		got, err := parseMemoryLimit(test.input)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("parseMemoryLimit(%q) = %d, expected error", test.input, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseMemoryLimit(%q): %v", test.input, err)
+			continue
+		}
+		if got != test.expected {
+			t.Errorf("parseMemoryLimit(%q) = %d, expected %d", test.input, got, test.expected)
+		}
+	}
+}
+
+func TestValidatePsiphonConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantErr bool
+	}{
+		{name: "valid", config: `{"PropagationChannelId": "ABCD", "SponsorId": "EF01"}`},
+		{name: "not_json", config: `{"PropagationChannelId": `, wantErr: true},
+		{name: "not_object", config: `["PropagationChannelId"]`, wantErr: true},
+		{name: "missing_sponsor", config: `{"PropagationChannelId": "ABCD"}`, wantErr: true},
+		{name: "empty_channel", config: `{"PropagationChannelId": "", "SponsorId": "EF01"}`, wantErr: true},
+		{name: "wrong_type", config: `{"PropagationChannelId": 1, "SponsorId": "EF01"}`, wantErr: true},
+	}
+
+	for _, test := range tests {
+		err := ValidatePsiphonConfig([]byte(test.config))
+		if test.wantErr && err == nil {
+			t.Errorf("%s: expected error", test.name)
+		}
+		if !test.wantErr && err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+		}
+	}
+}
+
+func TestDescribePsiphonConfig(t *testing.T) {
+	data := []byte(`{"PropagationChannelId": "ABCD", "SponsorId": "EF01", "AdditionalParameters": "secret"}`)
+
+	info, err := DescribePsiphonConfig(data)
+	if err != nil {
+		t.Fatalf("DescribePsiphonConfig: %v", err)
+	}
+	if info.PropagationChannelID != "ABCD" || info.SponsorID != "EF01" {
+		t.Fatalf("unexpected identifiers: %+v", info)
+	}
+	if len(info.Fingerprint) != 64 {
+		t.Fatalf("Fingerprint = %q, expected 64 hex characters", info.Fingerprint)
+	}
+
+	// Any change to the bytes must change the fingerprint
+	other, err := DescribePsiphonConfig([]byte(`{"PropagationChannelId": "ABCD", "SponsorId": "EF01", "AdditionalParameters": "other"}`))
+	if err != nil {
+		t.Fatalf("DescribePsiphonConfig: %v", err)
+	}
+	if other.Fingerprint == info.Fingerprint {
+		t.Fatalf("expected different fingerprints for different configs")
+	}
+}
+
+func TestLoadOrCreateEphemeral(t *testing.T) {
+	dir := t.TempDir()
+	dataDir := filepath.Join(dir, "data")
+	configPath := writeTempConfig(t, dir, `{}`)
+
+	cfg, err := LoadOrCreate(Options{
+		DataDir:           dataDir,
+		PsiphonConfigPath: configPath,
+		Ephemeral:         true,
+	})
+	if err != nil {
+		t.Fatalf("LoadOrCreate: %v", err)
+	}
+	defer os.RemoveAll(cfg.DataDir)
+
+	if !cfg.Ephemeral {
+		t.Fatalf("Ephemeral = false, expected true")
+	}
+	if cfg.KeyPair == nil || cfg.PrivateKeyBase64 == "" {
+		t.Fatalf("expected a generated key")
+	}
+	if cfg.DataDir == dataDir {
+		t.Fatalf("DataDir = %s, expected a temporary directory", cfg.DataDir)
+	}
+	if info, err := os.Stat(cfg.DataDir); err != nil || !info.IsDir() {
+		t.Fatalf("ephemeral data directory %s not created: %v", cfg.DataDir, err)
+	}
+	if _, err := os.Stat(dataDir); !os.IsNotExist(err) {
+		t.Fatalf("expected %s not to be created, stat err = %v", dataDir, err)
+	}
+
+	// Each ephemeral run gets its own identity
+	other, err := LoadOrCreate(Options{
+		DataDir:           dataDir,
+		PsiphonConfigPath: configPath,
+		Ephemeral:         true,
+	})
+	if err != nil {
+		t.Fatalf("LoadOrCreate: %v", err)
+	}
+	defer os.RemoveAll(other.DataDir)
+	if other.PrivateKeyBase64 == cfg.PrivateKeyBase64 {
+		t.Fatalf("expected a different key for each ephemeral run")
+	}
+
+	// A reload in the same run keeps its identity
+	reloaded, err := LoadOrCreate(Options{
+		DataDir:           dataDir,
+		PsiphonConfigPath: configPath,
+		Ephemeral:         true,
+		EphemeralKey:      cfg.KeyPair,
+	})
+	if err != nil {
+		t.Fatalf("LoadOrCreate: %v", err)
+	}
+	defer os.RemoveAll(reloaded.DataDir)
+	if reloaded.PrivateKeyBase64 != cfg.PrivateKeyBase64 {
+		t.Fatalf("expected the ephemeral key to be kept on reload")
+	}
+}
+
+func TestLoadOrCreateIdentitySeed(t *testing.T) {
+	configPath := writeTempConfig(t, t.TempDir(), `{}`)
+	const seed = "000102030405060708090a0b0c0d0e0f"
+
+	load := func(dataDir, seed, name string) *Config {
+		t.Helper()
+		cfg, err := LoadOrCreate(Options{DataDir: dataDir, PsiphonConfigPath: configPath, IdentitySeed: seed, InstanceName: name})
+		if err != nil {
+			t.Fatalf("LoadOrCreate: %v", err)
+		}
+		return cfg
+	}
+
+	first := load(t.TempDir(), seed, "inst-1")
+	if !first.IdentitySeeded {
+		t.Fatalf("IdentitySeeded = false, expected true")
+	}
+	if again := load(t.TempDir(), seed, "inst-1"); again.PrivateKeyBase64 != first.PrivateKeyBase64 {
+		t.Fatalf("expected the same key from the same seed and instance name")
+	}
+	if other := load(t.TempDir(), seed, "inst-2"); other.PrivateKeyBase64 == first.PrivateKeyBase64 {
+		t.Fatalf("expected a different key for a different instance name")
+	}
+	// An unnamed instance is reported as inst-0, and gets its key
+	if unnamed, named := load(t.TempDir(), seed, ""), load(t.TempDir(), seed, "inst-0"); unnamed.PrivateKeyBase64 != named.PrivateKeyBase64 {
+		t.Fatalf("expected the same key with no instance name as with inst-0")
+	}
+
+	// An existing key is kept whatever the seed says
+	dataDir := t.TempDir()
+	random := load(dataDir, "", "inst-1")
+	if kept := load(dataDir, seed, "inst-1"); kept.PrivateKeyBase64 != random.PrivateKeyBase64 {
+		t.Fatalf("expected the existing key to be kept")
+	}
+
+	for _, bad := range []string{"not-hex", "0011"} {
+		if _, err := LoadOrCreate(Options{DataDir: t.TempDir(), PsiphonConfigPath: configPath, IdentitySeed: bad}); err == nil {
+			t.Errorf("expected error for identity seed %q", bad)
+		}
+	}
+}
+
+func TestLoadOrCreateReadOnly(t *testing.T) {
+	dataDir := t.TempDir()
+	configPath := writeTempConfig(t, t.TempDir(), `{}`)
+
+	// Without a key there is nothing to run with
+	if _, err := LoadOrCreate(Options{DataDir: dataDir, PsiphonConfigPath: configPath, ReadOnlyData: true}); err == nil {
+		t.Fatalf("expected error for a read-only data directory without a key")
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, keyFileName)); !os.IsNotExist(err) {
+		t.Fatalf("read-only run created a key, stat err = %v", err)
+	}
+
+	created, err := LoadOrCreate(Options{DataDir: dataDir, PsiphonConfigPath: configPath})
+	if err != nil {
+		t.Fatalf("LoadOrCreate: %v", err)
+	}
+
+	cfg, err := LoadOrCreate(Options{DataDir: dataDir, PsiphonConfigPath: configPath, ReadOnlyData: true})
+	if err != nil {
+		t.Fatalf("LoadOrCreate: %v", err)
+	}
+	defer os.RemoveAll(cfg.DataDir)
+
+	if cfg.PrivateKeyBase64 != created.PrivateKeyBase64 {
+		t.Fatalf("expected the existing key")
+	}
+	if !cfg.ReadOnlyData || !cfg.TempDataDir || cfg.DataDir == dataDir {
+		t.Fatalf("expected a temporary data directory, got ReadOnlyData=%v TempDataDir=%v DataDir=%s", cfg.ReadOnlyData, cfg.TempDataDir, cfg.DataDir)
+	}
+
+	// Outputs must be redirected out of the data directory
+	_, err = LoadOrCreate(Options{
+		DataDir:           dataDir,
+		PsiphonConfigPath: configPath,
+		ReadOnlyData:      true,
+		StatsFile:         filepath.Join(dataDir, "stats.json"),
+	})
+	if err == nil {
+		t.Fatalf("expected error for a stats file in the read-only data directory")
+	}
+}
+
+func TestTLSProfile(t *testing.T) {
+	dataDir := t.TempDir()
+	configPath := writeTempConfig(t, dataDir, `{}`)
+
+	load := func(mode string) *Config {
+		t.Helper()
+		cfg, err := LoadOrCreate(Options{DataDir: dataDir, PsiphonConfigPath: configPath, FingerprintMode: mode})
+		if err != nil {
+			t.Fatalf("LoadOrCreate(%q): %v", mode, err)
+		}
+		return cfg
+	}
+
+	if profile := load("").TLSProfile(0); profile != "" {
+		t.Fatalf("default mode pinned profile %q, expected none", profile)
+	}
+	if profile := load(FingerprintRandom).TLSProfile(3); profile != "" {
+		t.Fatalf("random mode pinned profile %q, expected none", profile)
+	}
+
+	fixed := load(FingerprintFixed)
+	if fixed.TLSProfile(0) == "" || fixed.TLSProfile(0) != fixed.TLSProfile(5) {
+		t.Fatalf("fixed mode profiles %q and %q, expected the same non-empty profile", fixed.TLSProfile(0), fixed.TLSProfile(5))
+	}
+
+	roundRobin := load(FingerprintRoundRobin)
+	if roundRobin.TLSProfile(0) != fixed.TLSProfile(0) {
+		t.Fatalf("roundrobin starts at %q, expected the key's fixed profile %q", roundRobin.TLSProfile(0), fixed.TLSProfile(0))
+	}
+	if roundRobin.TLSProfile(0) == roundRobin.TLSProfile(1) {
+		t.Fatalf("roundrobin did not advance: %q", roundRobin.TLSProfile(0))
+	}
+	if roundRobin.TLSProfile(0) != roundRobin.TLSProfile(len(fingerprintProfiles)) {
+		t.Fatalf("roundrobin did not wrap around")
+	}
+
+	if _, err := LoadOrCreate(Options{DataDir: dataDir, PsiphonConfigPath: configPath, FingerprintMode: "bogus"}); err == nil {
+		t.Fatalf("expected error for an unknown fingerprint mode")
+	}
+}
+
+func TestIsValidInstanceName(t *testing.T) {
+	tests := map[string]bool{
+		"us-east-primary": true,
+		"node_1.eu":       true,
+		"7":               true,
+		"":                false,
+		"-leading":        false,
+		"has space":       false,
+		"quote\"":         false,
+		"100%":            false,
+	}
+	tests[strings.Repeat("a", 65)] = false
+
+	for name, expected := range tests {
+		if got := isValidInstanceName(name); got != expected {
+			t.Errorf("isValidInstanceName(%q) = %v, expected %v", name, got, expected)
+		}
+	}
+}
+
+func TestDiffPsiphonConfigs(t *testing.T) {
+	base := []byte(`{"SponsorId": "EF01", "PropagationChannelId": "ABCD", "Limits": {"A": 1, "B": [1, 2]}, "Gone": true}`)
+
+	// Formatting and key order don't matter
+	diffs, err := DiffPsiphonConfigs(base, []byte(`{
+		"PropagationChannelId": "ABCD",
+		"Gone": true,
+		"Limits": {"B": [1, 2], "A": 1.0},
+		"SponsorId": "EF01"
+	}`))
+	if err != nil {
+		t.Fatalf("DiffPsiphonConfigs: %v", err)
+	}
+	if len(diffs) != 0 {
+		t.Fatalf("diffs = %+v, expected none", diffs)
+	}
+
+	diffs, err = DiffPsiphonConfigs(base, []byte(`{"SponsorId": "FFFF", "PropagationChannelId": "ABCD", "Limits": {"A": 1, "B": [2, 1], "C": 3}, "New": 1}`))
+	if err != nil {
+		t.Fatalf("DiffPsiphonConfigs: %v", err)
+	}
+	expected := []ConfigDifference{
+		{Key: "Gone", Kind: ConfigKeyMissing},
+		{Key: "Limits.B", Kind: ConfigKeyChanged},
+		{Key: "Limits.C", Kind: ConfigKeyAdded},
+		{Key: "New", Kind: ConfigKeyAdded},
+		{Key: "SponsorId", Kind: ConfigKeyChanged},
+	}
+	if !reflect.DeepEqual(diffs, expected) {
+		t.Fatalf("diffs = %+v, expected %+v", diffs, expected)
+	}
+
+	if _, err := DiffPsiphonConfigs(base, []byte(`not json`)); err == nil {
+		t.Fatalf("expected an error for an invalid config")
+	}
+}
+
+func TestParseFileMode(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected os.FileMode
+		wantErr  bool
+	}{
+		{input: "", expected: 0},
+		{input: "0640", expected: 0640},
+		{input: "660", expected: 0660},
+		{input: "0600", expected: 0600},
+		{input: "0440", wantErr: true}, // Owner can't rewrite it
+		{input: "1777", wantErr: true},
+		{input: "0648", wantErr: true},
+		{input: "rw-r-----", wantErr: true},
+	}
+
+	for _, test := range tests {
+		got, err := parseFileMode(test.input)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("parseFileMode(%q) = %o, expected error", test.input, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseFileMode(%q): %v", test.input, err)
+			continue
+		}
+		if got != test.expected {
+			t.Errorf("parseFileMode(%q) = %o, expected %o", test.input, got, test.expected)
+		}
+	}
+}
+
+func TestCheckInfluxAddr(t *testing.T) {
+	tests := []struct {
+		addr    string
+		wantErr bool
+	}{
+		{addr: ""},
+		{addr: "udp://127.0.0.1:8089"},
+		{addr: "http://influx:8086/write?db=conduit"},
+		{addr: "https://influx.example.com/api/v2/write?org=o&bucket=b"},
+		{addr: "file:///var/log/conduit.lp"},
+		{addr: "udp://127.0.0.1", wantErr: true},
+		{addr: "http:///write", wantErr: true},
+		{addr: "file://metrics.lp", wantErr: true}, // Host, not a path
+		{addr: "127.0.0.1:8089", wantErr: true},
+		{addr: "tcp://127.0.0.1:8094", wantErr: true},
+	}
+
+	for _, test := range tests {
+		err := checkInfluxAddr(test.addr)
+		if test.wantErr && err == nil {
+			t.Errorf("checkInfluxAddr(%q): expected error", test.addr)
+		}
+		if !test.wantErr && err != nil {
+			t.Errorf("checkInfluxAddr(%q): %v", test.addr, err)
+		}
+	}
+}
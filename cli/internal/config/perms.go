@@ -0,0 +1,106 @@
+/*
+ * Copyright (c) 2026, Psiphon Inc.
+ * All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/Psiphon-Inc/conduit/cli/internal/logging"
+)
+
+// Permission bits that expose the identity. The key file must be private to
+// the owner. The data directory may be shared with a group, so that
+// monitoring can read a stats file kept there, but not with everyone.
+const (
+	keyFileExposedBits = 0077
+	dataDirExposedBits = 0007
+)
+
+// checkDataDirPerms warns about a data directory or key file that other users
+// can access. With strict it refuses to start instead, and with fix it removes
+// the excess permissions, unless the data directory is readOnly.
+func checkDataDirPerms(dataDir string, strict, fix, readOnly bool) error {
+	// Windows has no permission bits to check
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+
+	keyPath := filepath.Join(dataDir, keyFileName)
+	var keyMode os.FileMode
+	if info, err := os.Stat(keyPath); err == nil {
+		keyMode = info.Mode().Perm()
+	}
+
+	paths := []struct {
+		path    string
+		exposed os.FileMode
+	}{
+		{dataDir, dataDirExposedBits},
+		{keyPath, keyFileExposedBits},
+	}
+	for _, p := range paths {
+		info, err := os.Stat(p.path)
+		if err != nil {
+			// A key that doesn't exist yet is created 0600
+			continue
+		}
+		mode := info.Mode().Perm()
+		if mode&p.exposed == 0 {
+			continue
+		}
+
+		restricted := mode &^ p.exposed
+		hint := fmt.Sprintf("run chmod %04o %s or use --fix-perms", restricted, p.path)
+		if readOnly {
+			// Chmod would fail, so the fix belongs where the data dir is built
+			hint = fmt.Sprintf("the data directory is read-only, so run chmod %04o %s where it is built", restricted, p.path)
+		}
+		switch {
+		case fix && !readOnly:
+			if err := os.Chmod(p.path, restricted); err != nil {
+				return fmt.Errorf("failed to restrict permissions of %s: %w", p.path, err)
+			}
+			logging.Printf("[OK] Restricted permissions of %s from %04o to %04o\n", p.path, mode, restricted)
+		case strict:
+			return fmt.Errorf("%s has mode %04o, which %s; %s", p.path, mode, exposure(p.path == keyPath, mode, keyMode), hint)
+		default:
+			logging.Printf("[WARN] %s has mode %04o, which %s; %s\n", p.path, mode, exposure(p.path == keyPath, mode, keyMode), hint)
+		}
+	}
+	return nil
+}
+
+// exposure describes what mode gives other users, for the key file or the
+// data directory holding a key with keyMode (0 if there is none)
+func exposure(isKey bool, mode, keyMode os.FileMode) string {
+	switch {
+	case isKey && mode&0044 != 0, !isKey && keyMode&0004 != 0:
+		return "lets other users read the proxy identity"
+	case isKey:
+		return "lets other users change the proxy identity"
+	case mode&0002 != 0:
+		return "lets other users replace the proxy identity"
+	default:
+		return "lets other users look into the data directory"
+	}
+}
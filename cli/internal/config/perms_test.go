@@ -0,0 +1,111 @@
+/*
+ * Copyright (c) 2026, Psiphon Inc.
+ * All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestCheckDataDirPerms(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no permission bits on windows")
+	}
+
+	setup := func(dirMode, keyMode os.FileMode) string {
+		t.Helper()
+		dataDir := filepath.Join(t.TempDir(), "data")
+		if err := os.Mkdir(dataDir, 0700); err != nil {
+			t.Fatal(err)
+		}
+		keyPath := filepath.Join(dataDir, keyFileName)
+		if err := os.WriteFile(keyPath, []byte("{}"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		// Chmod rather than create with the mode, which the umask reduces
+		if err := os.Chmod(dataDir, dirMode); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(keyPath, keyMode); err != nil {
+			t.Fatal(err)
+		}
+		return dataDir
+	}
+	perm := func(path string) os.FileMode {
+		t.Helper()
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return info.Mode().Perm()
+	}
+
+	// Private, and shared with a group only, are both fine
+	for _, dirMode := range []os.FileMode{0700, 0750} {
+		if err := checkDataDirPerms(setup(dirMode, 0600), true, false, false); err != nil {
+			t.Errorf("data dir %04o: %v", dirMode, err)
+		}
+	}
+
+	// A readable key, or a data dir open to everyone, is refused when strict
+	for _, modes := range [][2]os.FileMode{{0700, 0640}, {0755, 0600}} {
+		if err := checkDataDirPerms(setup(modes[0], modes[1]), true, false, false); err == nil {
+			t.Errorf("data dir %04o, key %04o: expected error", modes[0], modes[1])
+		}
+	}
+
+	// Without strict it only warns
+	if err := checkDataDirPerms(setup(0755, 0644), false, false, false); err != nil {
+		t.Errorf("expected a warning only: %v", err)
+	}
+
+	// Fixing removes just the excess bits
+	dataDir := setup(0755, 0644)
+	if err := checkDataDirPerms(dataDir, true, true, false); err != nil {
+		t.Fatalf("checkDataDirPerms with fix: %v", err)
+	}
+	if got := perm(dataDir); got != 0750 {
+		t.Errorf("data dir mode = %04o, expected 0750", got)
+	}
+	if got := perm(filepath.Join(dataDir, keyFileName)); got != 0600 {
+		t.Errorf("key file mode = %04o, expected 0600", got)
+	}
+
+	// A read-only data dir is left alone, with a warning unless strict
+	dataDir = setup(0755, 0600)
+	if err := checkDataDirPerms(dataDir, false, true, true); err != nil {
+		t.Errorf("expected a warning only for a read-only data dir: %v", err)
+	}
+	if got := perm(dataDir); got != 0755 {
+		t.Errorf("read-only data dir mode = %04o, expected it unchanged", got)
+	}
+	err := checkDataDirPerms(dataDir, true, true, true)
+	if err == nil {
+		t.Fatalf("expected error for a read-only data dir when strict")
+	}
+
+	// A private key in an open data dir can't be read through it
+	if strings.Contains(err.Error(), "read the proxy identity") {
+		t.Errorf("error claims the 0600 key is readable: %v", err)
+	}
+}
@@ -0,0 +1,93 @@
+/*
+ * Copyright (c) 2026, Psiphon Inc.
+ * All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package control
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"time"
+)
+
+// Query sends a single command to the control socket at path and returns
+// the JSON response line
+func Query(path, command string, timeout time.Duration) ([]byte, error) {
+	conn, err := net.DialTimeout("unix", path, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to control socket: %w", err)
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write([]byte(command + "\n")); err != nil {
+		return nil, fmt.Errorf("failed to send command: %w", err)
+	}
+
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	return line, nil
+}
+
+// Subscription is an open event stream from a control socket
+type Subscription struct {
+	conn    net.Conn
+	scanner *bufio.Scanner
+}
+
+// Subscribe connects to the control socket at path and switches the
+// connection to streaming events
+func Subscribe(path string, timeout time.Duration) (*Subscription, error) {
+	conn, err := net.DialTimeout("unix", path, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to control socket: %w", err)
+	}
+	if _, err := conn.Write([]byte("subscribe\n")); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("failed to send command: %w", err)
+	}
+	return &Subscription{conn: conn, scanner: bufio.NewScanner(conn)}, nil
+}
+
+// Next blocks until the next event arrives. It returns an error once the
+// stream ends, e.g. because the service stopped.
+func (s *Subscription) Next() (Event, error) {
+	var event Event
+	if !s.scanner.Scan() {
+		if err := s.scanner.Err(); err != nil {
+			return event, err
+		}
+		return event, fmt.Errorf("control socket closed the event stream")
+	}
+	if err := json.Unmarshal(s.scanner.Bytes(), &event); err != nil {
+		return event, fmt.Errorf("invalid event: %w", err)
+	}
+	return event, nil
+}
+
+// Close ends the subscription
+func (s *Subscription) Close() error {
+	return s.conn.Close()
+}
@@ -0,0 +1,495 @@
+/*
+ * Copyright (c) 2026, Psiphon Inc.
+ * All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package control provides a local unix socket for querying and observing
+// a running Conduit service
+package control
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Psiphon-Inc/conduit/cli/internal/logging"
+	"github.com/Psiphon-Inc/conduit/cli/internal/systemd"
+)
+
+// Event types published on the control socket
+const (
+	EventClientConnect    = "client-connect"
+	EventClientDisconnect = "client-disconnect"
+	EventInstanceState    = "instance-state"
+	EventLimits           = "limits"
+	EventDropped          = "dropped"
+)
+
+// subscriberBufferSize is the number of events buffered per subscriber.
+// Events for a subscriber whose buffer is full are dropped so that a slow
+// reader can never block the service.
+const subscriberBufferSize = 256
+
+// maxCommandLength is the longest command line accepted, not counting the
+// newline. Longer input gets an error and the connection is closed.
+const maxCommandLength = 1024
+
+// idleTimeout closes a connection that doesn't complete a command in time,
+// so a stalled or half-written client can't hold a handler forever.
+// Subscribers are exempt.
+const idleTimeout = 5 * time.Minute
+
+// errPartialCommand is returned by scanCommands when the client hung up
+// partway through a line
+var errPartialCommand = errors.New("connection closed before the end of the command")
+
+// Event is a single newline-delimited JSON message sent to subscribers
+type Event struct {
+	Type      string         `json:"type"`
+	Timestamp string         `json:"timestamp"`
+	Data      map[string]any `json:"data,omitempty"`
+}
+
+// Funcs holds functions that compute command responses on demand
+type Funcs struct {
+	GetStatus        func() any
+	GetInstanceStats func() []InstanceStats
+	WriteMetrics     func(w io.Writer) error // Prometheus text format
+	Reload           func() error            // Asks for a reload, as SIGHUP does
+	ResetPeak        func() int              // Resets the peak clients, returning the old peak
+	Diagnostics      func() (string, error)  // Writes a diagnostics bundle, returning its path
+}
+
+// InstanceStats is one row of the stats command. The CSV columns follow the
+// field order and must not be reordered; new columns are only appended.
+type InstanceStats struct {
+	Index       int    `json:"index"`
+	State       string `json:"state"`
+	Clients     int    `json:"clients"`
+	BytesUp     int64  `json:"bytesUp"`
+	BytesDown   int64  `json:"bytesDown"`
+	Throughput  int64  `json:"throughput"`  // Bytes per second, both directions
+	Name        string `json:"name"`        // Instance name, or inst-<index> if unnamed
+	PeakClients int    `json:"peakClients"` // Most clients at once since the peak was last reset
+}
+
+// statsCSVHeader is the header row of the CSV stats format
+var statsCSVHeader = []string{"index", "state", "clients", "bytes_up", "bytes_down", "throughput", "name", "peak_clients"}
+
+// subscriber is a connection that has switched to streaming mode
+type subscriber struct {
+	events  chan Event
+	dropped int
+}
+
+// Server is the control socket server
+type Server struct {
+	funcs    Funcs
+	path     string
+	listener net.Listener
+
+	mu          sync.Mutex
+	conns       map[net.Conn]struct{}
+	subscribers map[*subscriber]struct{}
+	closed      bool
+	wg          sync.WaitGroup
+}
+
+// New creates a new control socket server
+func New(funcs Funcs) *Server {
+	return &Server{
+		funcs:       funcs,
+		conns:       make(map[net.Conn]struct{}),
+		subscribers: make(map[*subscriber]struct{}),
+	}
+}
+
+// Start listens on the unix socket at path and serves connections in the background
+func (s *Server) Start(path string) error {
+	// Prefer a socket passed in by systemd socket activation. systemd owns
+	// that socket file, so it is not removed on shutdown.
+	listener, err := systemd.Listener(systemd.ControlSocketName)
+	if err != nil {
+		return err
+	}
+	if listener != nil {
+		logging.Printf("[OK] Using socket-activated control listener %s\n", listener.Addr())
+		s.listener = listener
+		s.wg.Add(1)
+		go s.acceptLoop()
+		return nil
+	}
+
+	// Remove a stale socket left behind by an unclean exit, but never
+	// clobber a regular file the user pointed us at by mistake
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return fmt.Errorf("control socket path %s exists and is not a socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("failed to remove stale control socket: %w", err)
+		}
+	}
+
+	listener, err = net.Listen("unix", path)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		_ = listener.Close()
+		return fmt.Errorf("failed to set control socket permissions: %w", err)
+	}
+
+	s.path = path
+	s.listener = listener
+
+	s.wg.Add(1)
+	go s.acceptLoop()
+
+	return nil
+}
+
+// Shutdown closes the listener and all open connections, then removes the socket file
+func (s *Server) Shutdown() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.mu.Unlock()
+
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	s.wg.Wait()
+
+	if s.path != "" {
+		if rmErr := os.Remove(s.path); rmErr != nil && !errors.Is(rmErr, os.ErrNotExist) && err == nil {
+			err = rmErr
+		}
+	}
+
+	return err
+}
+
+// Publish fans out an event to all subscribers without blocking
+func (s *Server) Publish(eventType string, data map[string]any) {
+	event := Event{
+		Type:      eventType,
+		Timestamp: time.Now().Format(time.RFC3339),
+		Data:      data,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for sub := range s.subscribers {
+		select {
+		case sub.events <- event:
+		default:
+			sub.dropped++
+		}
+	}
+}
+
+func (s *Server) acceptLoop() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				logging.Printf("[ERROR] Control socket accept error: %v\n", err)
+			}
+			return
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			_ = conn.Close()
+			return
+		}
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go s.handleConn(conn)
+	}
+}
+
+// handleConn reads newline-delimited commands and writes one JSON line per
+// response until the client disconnects or subscribes
+func (s *Server) handleConn(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		_ = conn.Close()
+	}()
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 0, 256), maxCommandLength+len("\r\n"))
+	scanner.Split(scanCommands)
+	encoder := json.NewEncoder(conn)
+
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(idleTimeout))
+		if !scanner.Scan() {
+			s.writeProtocolError(encoder, scanner.Err())
+			return
+		}
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		command := fields[0]
+
+		switch command {
+		case "status":
+			var status any
+			if s.funcs.GetStatus != nil {
+				status = s.funcs.GetStatus()
+			}
+			if err := encoder.Encode(status); err != nil {
+				return
+			}
+
+		case "stats":
+			if err := s.writeStats(conn, encoder, fields[1:]); err != nil {
+				return
+			}
+
+		case "metrics":
+			if err := s.writeMetrics(conn, encoder); err != nil {
+				return
+			}
+
+		case "reload":
+			response := map[string]any{"ok": true}
+			if s.funcs.Reload == nil {
+				response = map[string]any{"error": "reload is not available"}
+			} else if err := s.funcs.Reload(); err != nil {
+				response = map[string]any{"error": err.Error()}
+			}
+			if err := encoder.Encode(response); err != nil {
+				return
+			}
+
+		case "reset-peak":
+			response := map[string]any{"error": "reset-peak is not available"}
+			if s.funcs.ResetPeak != nil {
+				response = map[string]any{"ok": true, "previousPeak": s.funcs.ResetPeak()}
+			}
+			if err := encoder.Encode(response); err != nil {
+				return
+			}
+
+		case "diagnostics":
+			response := map[string]any{"error": "diagnostics is not available"}
+			if s.funcs.Diagnostics != nil {
+				if path, err := s.funcs.Diagnostics(); err != nil {
+					response = map[string]any{"error": err.Error()}
+				} else {
+					response = map[string]any{"ok": true, "path": path}
+				}
+			}
+			if err := encoder.Encode(response); err != nil {
+				return
+			}
+
+		case "subscribe":
+			s.stream(conn, encoder)
+			return
+
+		default:
+			if err := encoder.Encode(map[string]string{"error": fmt.Sprintf("unknown command %q", command)}); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// scanCommands splits input into newline-terminated commands. Unlike
+// bufio.ScanLines, a final line without a newline is an error rather than a
+// command, so a client that disconnects mid-write never has half a command run.
+func scanCommands(data []byte, atEOF bool) (int, []byte, error) {
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		line := bytes.TrimSuffix(data[:i], []byte("\r"))
+		if len(line) > maxCommandLength {
+			return 0, nil, bufio.ErrTooLong
+		}
+		return i + 1, line, nil
+	}
+	if atEOF && len(data) > 0 {
+		return 0, nil, errPartialCommand
+	}
+	return 0, nil, nil
+}
+
+// writeProtocolError tells the client why its connection is being closed.
+// A plain EOF or a closed connection needs no answer. The write is best
+// effort, since a client that hung up can't read it.
+func (s *Server) writeProtocolError(encoder *json.Encoder, err error) {
+	var message string
+	var netErr net.Error
+	switch {
+	case err == nil, errors.Is(err, net.ErrClosed):
+		return
+	case errors.Is(err, bufio.ErrTooLong):
+		message = fmt.Sprintf("command longer than %d bytes", maxCommandLength)
+	case errors.Is(err, errPartialCommand):
+		message = "incomplete command (missing newline)"
+	case errors.As(err, &netErr) && netErr.Timeout():
+		message = fmt.Sprintf("no command for %s, closing connection", idleTimeout)
+	default:
+		return
+	}
+	_ = encoder.Encode(map[string]string{"error": message})
+}
+
+// writeStats answers the stats command: a JSON array of rows by default, or
+// with "--format csv" a header and one row per instance followed by an empty line
+func (s *Server) writeStats(conn net.Conn, encoder *json.Encoder, args []string) error {
+	format := "json"
+	switch {
+	case len(args) == 0:
+	case len(args) == 2 && args[0] == "--format":
+		format = args[1]
+	default:
+		return encoder.Encode(map[string]string{"error": "usage: stats [--format json|csv]"})
+	}
+
+	rows := []InstanceStats{}
+	if s.funcs.GetInstanceStats != nil {
+		rows = s.funcs.GetInstanceStats()
+	}
+
+	switch format {
+	case "json":
+		return encoder.Encode(rows)
+
+	case "csv":
+		writer := csv.NewWriter(conn)
+		_ = writer.Write(statsCSVHeader)
+		for _, row := range rows {
+			_ = writer.Write([]string{
+				strconv.Itoa(row.Index),
+				row.State,
+				strconv.Itoa(row.Clients),
+				strconv.FormatInt(row.BytesUp, 10),
+				strconv.FormatInt(row.BytesDown, 10),
+				strconv.FormatInt(row.Throughput, 10),
+				row.Name,
+				strconv.Itoa(row.PeakClients),
+			})
+		}
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			return err
+		}
+		_, err := conn.Write([]byte("\n"))
+		return err
+
+	default:
+		return encoder.Encode(map[string]string{"error": fmt.Sprintf("unknown stats format %q (use json or csv)", format)})
+	}
+}
+
+// writeMetrics answers the metrics command with the Prometheus text
+// exposition, as served by /metrics, followed by an empty line
+func (s *Server) writeMetrics(conn net.Conn, encoder *json.Encoder) error {
+	if s.funcs.WriteMetrics == nil {
+		return encoder.Encode(map[string]string{"error": "metrics are not available"})
+	}
+	writer := bufio.NewWriter(conn)
+	if err := s.funcs.WriteMetrics(writer); err != nil {
+		return err
+	}
+	if _, err := writer.WriteString("\n"); err != nil {
+		return err
+	}
+	return writer.Flush()
+}
+
+// stream switches the connection into streaming mode, writing events until
+// the client disconnects or the server shuts down
+func (s *Server) stream(conn net.Conn, encoder *json.Encoder) {
+	sub := &subscriber{events: make(chan Event, subscriberBufferSize)}
+	_ = conn.SetReadDeadline(time.Time{})
+
+	s.mu.Lock()
+	s.subscribers[sub] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.subscribers, sub)
+		s.mu.Unlock()
+	}()
+
+	// The client sends nothing further once subscribed; a read returning
+	// means it hung up (or the server closed the connection)
+	disconnected := make(chan struct{})
+	go func() {
+		_, _ = conn.Read(make([]byte, 1))
+		close(disconnected)
+	}()
+
+	for {
+		select {
+		case <-disconnected:
+			return
+		case event := <-sub.events:
+			// Let the subscriber know it fell behind before resuming
+			s.mu.Lock()
+			dropped := sub.dropped
+			sub.dropped = 0
+			s.mu.Unlock()
+			if dropped > 0 {
+				notice := Event{
+					Type:      EventDropped,
+					Timestamp: time.Now().Format(time.RFC3339),
+					Data:      map[string]any{"count": dropped},
+				}
+				if err := encoder.Encode(notice); err != nil {
+					return
+				}
+			}
+
+			if err := encoder.Encode(event); err != nil {
+				return
+			}
+		}
+	}
+}
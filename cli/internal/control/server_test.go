@@ -0,0 +1,519 @@
+/*
+ * Copyright (c) 2026, Psiphon Inc.
+ * All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package control
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func startTestServer(t *testing.T, funcs Funcs) (*Server, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "conduit.sock")
+	s := New(funcs)
+	if err := s.Start(path); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := s.Shutdown(); err != nil {
+			t.Errorf("Shutdown: %v", err)
+		}
+	})
+	return s, path
+}
+
+func dial(t *testing.T, path string) (net.Conn, *bufio.Reader) {
+	t.Helper()
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatalf("dial control socket: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	return conn, bufio.NewReader(conn)
+}
+
+func TestStatusCommand(t *testing.T) {
+	_, path := startTestServer(t, Funcs{
+		GetStatus: func() any { return map[string]int{"connectedClients": 3} },
+	})
+	conn, reader := dial(t, path)
+
+	if _, err := conn.Write([]byte("status\n")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	line, err := reader.ReadBytes('\n')
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+
+	var status map[string]int
+	if err := json.Unmarshal(line, &status); err != nil {
+		t.Fatalf("unmarshal %q: %v", line, err)
+	}
+	if status["connectedClients"] != 3 {
+		t.Fatalf("connectedClients = %d, expected 3", status["connectedClients"])
+	}
+}
+
+func TestUnknownCommand(t *testing.T) {
+	_, path := startTestServer(t, Funcs{})
+	conn, reader := dial(t, path)
+
+	if _, err := conn.Write([]byte("bogus\n")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	line, err := reader.ReadBytes('\n')
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+
+	var resp map[string]string
+	if err := json.Unmarshal(line, &resp); err != nil {
+		t.Fatalf("unmarshal %q: %v", line, err)
+	}
+	if resp["error"] == "" {
+		t.Fatalf("expected error response, got %q", line)
+	}
+}
+
+func TestSubscribeReceivesEvents(t *testing.T) {
+	s, path := startTestServer(t, Funcs{})
+	conn, reader := dial(t, path)
+
+	if _, err := conn.Write([]byte("subscribe\n")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	// Publish until the subscription is registered and an event arrives
+	received := make(chan Event, 1)
+	go func() {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			return
+		}
+		var event Event
+		if err := json.Unmarshal(line, &event); err == nil {
+			received <- event
+		}
+	}()
+
+	deadline := time.After(5 * time.Second)
+	for {
+		s.Publish(EventInstanceState, map[string]any{"state": "live"})
+		select {
+		case event := <-received:
+			if event.Type != EventInstanceState {
+				t.Fatalf("event type = %q, expected %q", event.Type, EventInstanceState)
+			}
+			if event.Data["state"] != "live" {
+				t.Fatalf("event state = %v, expected live", event.Data["state"])
+			}
+			return
+		case <-deadline:
+			t.Fatalf("timed out waiting for event")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func TestPublishDoesNotBlockOnSlowSubscriber(t *testing.T) {
+	s := New(Funcs{})
+	sub := &subscriber{events: make(chan Event, 1)}
+	s.subscribers[sub] = struct{}{}
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < subscriberBufferSize*2; i++ {
+			s.Publish(EventClientConnect, nil)
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Publish blocked on a subscriber that is not reading")
+	}
+
+	if sub.dropped != subscriberBufferSize*2-1 {
+		t.Fatalf("dropped = %d, expected %d", sub.dropped, subscriberBufferSize*2-1)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	_, path := startTestServer(t, Funcs{
+		GetStatus: func() any { return map[string]bool{"isLive": true} },
+	})
+
+	line, err := Query(path, "status", 5*time.Second)
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+
+	var status map[string]bool
+	if err := json.Unmarshal(line, &status); err != nil {
+		t.Fatalf("unmarshal %q: %v", line, err)
+	}
+	if !status["isLive"] {
+		t.Fatalf("isLive = false, expected true")
+	}
+}
+
+func TestQueryNoServer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing.sock")
+	if _, err := Query(path, "status", time.Second); err == nil {
+		t.Fatalf("expected error querying a socket nobody is listening on")
+	}
+}
+
+func TestStatsCommand(t *testing.T) {
+	_, path := startTestServer(t, Funcs{
+		GetInstanceStats: func() []InstanceStats {
+			return []InstanceStats{{Index: 0, State: "live", Clients: 4, BytesUp: 100, BytesDown: 2000, Throughput: 512, Name: "inst-0", PeakClients: 9}}
+		},
+	})
+	conn, reader := dial(t, path)
+
+	if _, err := conn.Write([]byte("stats\n")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	line, err := reader.ReadBytes('\n')
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	var rows []InstanceStats
+	if err := json.Unmarshal(line, &rows); err != nil {
+		t.Fatalf("unmarshal %q: %v", line, err)
+	}
+	if len(rows) != 1 || rows[0].Clients != 4 {
+		t.Fatalf("rows = %+v, expected one row with 4 clients", rows)
+	}
+
+	if _, err := conn.Write([]byte("stats --format csv\n")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	var lines []string
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		if line == "\n" {
+			break
+		}
+		lines = append(lines, line)
+	}
+	expected := []string{
+		"index,state,clients,bytes_up,bytes_down,throughput,name,peak_clients\n",
+		"0,live,4,100,2000,512,inst-0,9\n",
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("csv = %q, expected %q", lines, expected)
+	}
+	for i := range expected {
+		if lines[i] != expected[i] {
+			t.Fatalf("csv line %d = %q, expected %q", i, lines[i], expected[i])
+		}
+	}
+
+	if _, err := conn.Write([]byte("stats --format xml\n")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	line, err = reader.ReadBytes('\n')
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if !strings.Contains(string(line), "error") {
+		t.Fatalf("expected error for unknown format, got %q", line)
+	}
+}
+
+func TestReloadCommand(t *testing.T) {
+	reloads := 0
+	_, path := startTestServer(t, Funcs{
+		Reload: func() error {
+			reloads++
+			return nil
+		},
+	})
+
+	line, err := Query(path, "reload", 5*time.Second)
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if strings.TrimSpace(string(line)) != `{"ok":true}` || reloads != 1 {
+		t.Fatalf("reload = %q after %d reloads, expected ok after 1", line, reloads)
+	}
+
+	_, path = startTestServer(t, Funcs{})
+	line, err = Query(path, "reload", 5*time.Second)
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if !strings.Contains(string(line), "error") {
+		t.Fatalf("expected error without a reload func, got %q", line)
+	}
+}
+
+func TestResetPeakCommand(t *testing.T) {
+	peak := 7
+	_, path := startTestServer(t, Funcs{
+		ResetPeak: func() int {
+			previous := peak
+			peak = 0
+			return previous
+		},
+	})
+
+	line, err := Query(path, "reset-peak", 5*time.Second)
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if strings.TrimSpace(string(line)) != `{"ok":true,"previousPeak":7}` || peak != 0 {
+		t.Fatalf("reset-peak = %q with peak %d, expected ok with previous peak 7", line, peak)
+	}
+
+	_, path = startTestServer(t, Funcs{})
+	line, err = Query(path, "reset-peak", 5*time.Second)
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if !strings.Contains(string(line), "error") {
+		t.Fatalf("expected error without a reset func, got %q", line)
+	}
+}
+
+func TestDiagnosticsCommand(t *testing.T) {
+	_, path := startTestServer(t, Funcs{
+		Diagnostics: func() (string, error) {
+			return "/data/diagnostics.txt", nil
+		},
+	})
+
+	line, err := Query(path, "diagnostics", 5*time.Second)
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if strings.TrimSpace(string(line)) != `{"ok":true,"path":"/data/diagnostics.txt"}` {
+		t.Fatalf("diagnostics = %q, expected ok with the path", line)
+	}
+
+	_, path = startTestServer(t, Funcs{
+		Diagnostics: func() (string, error) {
+			return "", errors.New("disk full")
+		},
+	})
+	line, err = Query(path, "diagnostics", 5*time.Second)
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if !strings.Contains(string(line), "disk full") {
+		t.Fatalf("expected the write error, got %q", line)
+	}
+}
+
+func TestMetricsCommand(t *testing.T) {
+	_, path := startTestServer(t, Funcs{
+		WriteMetrics: func(w io.Writer) error {
+			_, err := io.WriteString(w, "# TYPE conduit_connected_clients gauge\nconduit_connected_clients 3\n")
+			return err
+		},
+	})
+	conn, reader := dial(t, path)
+
+	// Twice, to check the empty line ends each response
+	for range 2 {
+		if _, err := conn.Write([]byte("metrics\n")); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		var lines []string
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				t.Fatalf("read: %v", err)
+			}
+			if line == "\n" {
+				break
+			}
+			lines = append(lines, line)
+		}
+		if len(lines) != 2 || lines[1] != "conduit_connected_clients 3\n" {
+			t.Fatalf("metrics = %q, expected the exposition text", lines)
+		}
+	}
+}
+
+func TestSubscription(t *testing.T) {
+	s, path := startTestServer(t, Funcs{})
+
+	sub, err := Subscribe(path, 5*time.Second)
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	defer sub.Close()
+
+	// Publish until the subscription is registered
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(10 * time.Millisecond):
+				s.Publish(EventClientConnect, nil)
+			}
+		}
+	}()
+
+	event, err := sub.Next()
+	if err != nil {
+		t.Fatalf("Next: %v", err)
+	}
+	if event.Type != EventClientConnect {
+		t.Fatalf("event type = %q, expected %q", event.Type, EventClientConnect)
+	}
+
+	if err := s.Shutdown(); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	for {
+		if _, err := sub.Next(); err != nil {
+			break
+		}
+	}
+}
+
+func TestMalformedInput(t *testing.T) {
+	var statusCalls atomic.Int32
+	s, path := startTestServer(t, Funcs{
+		GetStatus: func() any {
+			statusCalls.Add(1)
+			return map[string]int{}
+		},
+	})
+
+	readError := func(t *testing.T, reader *bufio.Reader) string {
+		t.Helper()
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		var response map[string]string
+		if err := json.Unmarshal(line, &response); err != nil {
+			t.Fatalf("unmarshal %q: %v", line, err)
+		}
+		return response["error"]
+	}
+
+	t.Run("oversized", func(t *testing.T) {
+		conn, reader := dial(t, path)
+		if _, err := conn.Write([]byte(strings.Repeat("x", maxCommandLength+1) + "\n")); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		if msg := readError(t, reader); !strings.Contains(msg, "longer than") {
+			t.Errorf("error = %q, expected a length error", msg)
+		}
+		// Closing with the newline unread may reset rather than end the stream
+		if _, err := reader.ReadByte(); err == nil {
+			t.Errorf("expected the connection to be closed")
+		}
+	})
+
+	t.Run("longest accepted", func(t *testing.T) {
+		conn, reader := dial(t, path)
+		command := "status" + strings.Repeat(" ", maxCommandLength-len("status"))
+		if _, err := conn.Write([]byte(command + "\r\n")); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		if _, err := reader.ReadBytes('\n'); err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		if statusCalls.Load() != 1 {
+			t.Errorf("status ran %d times, expected 1", statusCalls.Load())
+		}
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		conn, reader := dial(t, path)
+		if _, err := conn.Write([]byte("status\nstat")); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		_ = conn.(*net.UnixConn).CloseWrite()
+		if _, err := reader.ReadBytes('\n'); err != nil {
+			t.Fatalf("read status: %v", err)
+		}
+		if msg := readError(t, reader); !strings.Contains(msg, "incomplete") {
+			t.Errorf("error = %q, expected an incomplete command error", msg)
+		}
+		if statusCalls.Load() != 2 {
+			t.Errorf("status ran %d times, expected 2", statusCalls.Load())
+		}
+	})
+
+	t.Run("abrupt disconnect", func(t *testing.T) {
+		conn, _ := dial(t, path)
+		if _, err := conn.Write([]byte("stat")); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		_ = conn.Close()
+
+		// The handler must notice and forget the connection
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			s.mu.Lock()
+			open := len(s.conns)
+			s.mu.Unlock()
+			if open == 0 {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("%d connections still tracked after disconnect", open)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+}
+
+func TestScanCommands(t *testing.T) {
+	scanner := bufio.NewScanner(strings.NewReader("status\r\n\nstats --format csv\npartial"))
+	scanner.Split(scanCommands)
+
+	var commands []string
+	for scanner.Scan() {
+		commands = append(commands, scanner.Text())
+	}
+	expected := []string{"status", "", "stats --format csv"}
+	if strings.Join(commands, "|") != strings.Join(expected, "|") {
+		t.Errorf("commands = %q, expected %q", commands, expected)
+	}
+	if !errors.Is(scanner.Err(), errPartialCommand) {
+		t.Errorf("Err() = %v, expected errPartialCommand", scanner.Err())
+	}
+}
@@ -69,6 +69,24 @@ func GenerateMnemonic() (string, error) {
 	return mnemonic, nil
 }
 
+// MnemonicFromSeed deterministically derives a BIP-39 mnemonic from seed,
+// with index separating the mnemonics of different instances. Anyone with
+// the seed can recreate the key, so this is only for test deployments.
+func MnemonicFromSeed(seed []byte, index string) (string, error) {
+	hkdfReader := hkdf.New(sha256.New, seed, nil, []byte("conduit-identity-seed"+index))
+	entropy := make([]byte, 32) // 24 words, as GenerateMnemonic
+	if _, err := io.ReadFull(hkdfReader, entropy); err != nil {
+		return "", fmt.Errorf("failed to derive entropy: %w", err)
+	}
+
+	mnemonic, err := bip39.NewMnemonic(entropy)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate mnemonic: %w", err)
+	}
+
+	return mnemonic, nil
+}
+
 // DeriveKeyPairFromMnemonic derives an Ed25519 key pair from a BIP-39 mnemonic
 // Uses HKDF to derive the key from the mnemonic seed
 func DeriveKeyPairFromMnemonic(mnemonic string, path string) (*KeyPair, error) {
@@ -82,6 +82,34 @@ func TestDeriveKeyPairFromMnemonicDeterministic(t *testing.T) {
 	}
 }
 
+func TestMnemonicFromSeed(t *testing.T) {
+	seed := []byte("0123456789abcdef")
+
+	first, err := MnemonicFromSeed(seed, "inst-1")
+	if err != nil {
+		t.Fatalf("MnemonicFromSeed failed: %v", err)
+	}
+	again, err := MnemonicFromSeed(seed, "inst-1")
+	if err != nil {
+		t.Fatalf("MnemonicFromSeed failed: %v", err)
+	}
+	if first != again {
+		t.Fatalf("mnemonics differ for the same seed and index")
+	}
+
+	other, err := MnemonicFromSeed(seed, "inst-2")
+	if err != nil {
+		t.Fatalf("MnemonicFromSeed failed: %v", err)
+	}
+	if first == other {
+		t.Fatalf("mnemonics are the same for different indexes")
+	}
+
+	if _, err := DeriveKeyPairFromMnemonic(first, ""); err != nil {
+		t.Fatalf("derived mnemonic is not usable: %v", err)
+	}
+}
+
 func TestKeyPairToBase64NoPad(t *testing.T) {
 
 	prvKeyB64str := "251pKn4kZiRYtyvGzvl7/taXkQ5dXY7JvuraIrClHa8hzAL4Lnj84SZK7HcCiBSXFy8u1tN+cLHw11UvQk3ZzA"
@@ -0,0 +1,308 @@
+/*
+ * Copyright (c) 2026, Psiphon Inc.
+ * All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package deploy generates systemd and Docker deployment artifacts for Conduit
+package deploy
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/Psiphon-Inc/conduit/cli/internal/config"
+)
+
+// Defaults used by the generated artifacts
+const (
+	DefaultBinaryPath = "/usr/local/bin/conduit"
+	DefaultImage      = "ghcr.io/psiphon-inc/conduit/cli:latest"
+
+	// Paths inside the published container image
+	containerDataDir      = "/home/conduit/data"
+	containerConfigPath   = "/home/conduit/psiphon_config.json"
+	containerMetricsPort  = "9090"
+	systemdStateDirectory = "conduit"
+
+	// Open file estimate: a fixed allowance for the broker connections,
+	// data store and metrics/control listeners, plus each client's WebRTC
+	// sockets and the upstream connection it is relayed to
+	baseOpenFiles      = 1024
+	openFilesPerClient = 16
+)
+
+// Options describes the deployment to generate
+type Options struct {
+	Instances         int
+	MaxClients        int     // 0 = conduit default
+	BandwidthMbps     float64 // Only used if BandwidthSet
+	BandwidthSet      bool
+	MetricsAddr       string // Empty = metrics disabled
+	PsiphonConfigPath string // Empty = use the config embedded in the binary or image
+	BinaryPath        string // systemd only
+	Image             string // Docker only
+}
+
+// Validate checks the options use the same ranges as conduit start
+func (o Options) Validate() error {
+	if o.Instances < 1 {
+		return fmt.Errorf("instances must be at least 1")
+	}
+	if o.MaxClients < 0 || o.MaxClients > config.MaxClientsLimit {
+		return fmt.Errorf("max-clients must be between 1 and %d", config.MaxClientsLimit)
+	}
+	if o.BandwidthSet && o.BandwidthMbps != config.UnlimitedBandwidth && o.BandwidthMbps < 1 {
+		return fmt.Errorf("bandwidth must be at least 1 Mbps (or -1 for unlimited)")
+	}
+	if o.MetricsAddr != "" {
+		if _, _, err := splitMetricsAddr(o.MetricsAddr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EstimateOpenFiles returns a LimitNOFILE value that comfortably covers an
+// instance serving maxClients clients
+func EstimateOpenFiles(maxClients int) int {
+	if maxClients == 0 {
+		maxClients = config.DefaultMaxClients
+	}
+	return baseOpenFiles + maxClients*openFilesPerClient
+}
+
+// SystemdUnit returns a unit file for the deployment. Multiple instances are
+// served by a template unit (conduit@.service) with a state directory per
+// instance.
+func SystemdUnit(opts Options) (string, error) {
+	if err := opts.Validate(); err != nil {
+		return "", err
+	}
+	if opts.Instances > 1 && opts.MetricsAddr != "" {
+		// A template unit can't derive a distinct port from the instance name
+		return "", fmt.Errorf("metrics-addr is not supported with more than one systemd instance; set it per instance with a drop-in")
+	}
+
+	binaryPath := opts.BinaryPath
+	if binaryPath == "" {
+		binaryPath = DefaultBinaryPath
+	}
+
+	stateDirectory := systemdStateDirectory
+	description := "Psiphon Conduit"
+	if opts.Instances > 1 {
+		stateDirectory += "/%i"
+		description += " (instance %i)"
+	}
+
+	args := append([]string{binaryPath}, startArgs(opts, "${STATE_DIRECTORY}", opts.MetricsAddr, opts.PsiphonConfigPath)...)
+
+	var b strings.Builder
+	if opts.Instances > 1 {
+		fmt.Fprintf(&b, "# Save as /etc/systemd/system/conduit@.service, then run:\n")
+		fmt.Fprintf(&b, "#   systemctl daemon-reload\n")
+		fmt.Fprintf(&b, "#   systemctl enable --now conduit@{1..%d}\n", opts.Instances)
+	} else {
+		fmt.Fprintf(&b, "# Save as /etc/systemd/system/conduit.service, then run:\n")
+		fmt.Fprintf(&b, "#   systemctl daemon-reload\n")
+		fmt.Fprintf(&b, "#   systemctl enable --now conduit\n")
+	}
+	fmt.Fprintf(&b, "\n[Unit]\n")
+	fmt.Fprintf(&b, "Description=%s\n", description)
+	fmt.Fprintf(&b, "Wants=network-online.target\n")
+	fmt.Fprintf(&b, "After=network-online.target\n")
+	fmt.Fprintf(&b, "\n[Service]\n")
+	// Started once live with the broker, which can take a few minutes
+	fmt.Fprintf(&b, "Type=notify\n")
+	fmt.Fprintf(&b, "TimeoutStartSec=5min\n")
+	fmt.Fprintf(&b, "ExecStart=%s\n", systemdJoin(args))
+	fmt.Fprintf(&b, "ExecReload=/bin/kill -HUP $MAINPID\n")
+	fmt.Fprintf(&b, "Restart=on-failure\n")
+	fmt.Fprintf(&b, "RestartSec=10s\n")
+	// Restarting won't help until conduit is upgraded
+	fmt.Fprintf(&b, "RestartPreventExitStatus=%d\n", config.ExitMustUpgrade)
+	fmt.Fprintf(&b, "LimitNOFILE=%d\n", EstimateOpenFiles(opts.MaxClients))
+	fmt.Fprintf(&b, "DynamicUser=yes\n")
+	fmt.Fprintf(&b, "StateDirectory=%s\n", stateDirectory)
+	fmt.Fprintf(&b, "StateDirectoryMode=0700\n")
+	fmt.Fprintf(&b, "NoNewPrivileges=yes\n")
+	fmt.Fprintf(&b, "ProtectSystem=strict\n")
+	fmt.Fprintf(&b, "ProtectHome=read-only\n")
+	fmt.Fprintf(&b, "PrivateTmp=yes\n")
+	fmt.Fprintf(&b, "\n[Install]\n")
+	fmt.Fprintf(&b, "WantedBy=multi-user.target\n")
+
+	return b.String(), nil
+}
+
+// Dockerfile returns a Dockerfile that runs the published image with the
+// deployment's flags
+func Dockerfile(opts Options) (string, error) {
+	if err := opts.Validate(); err != nil {
+		return "", err
+	}
+	if opts.Instances > 1 {
+		return "", fmt.Errorf("a Dockerfile describes a single instance; use compose for multiple instances")
+	}
+
+	configPath := ""
+	if opts.PsiphonConfigPath != "" {
+		configPath = containerConfigPath
+	}
+	metricsAddr := ""
+	if opts.MetricsAddr != "" {
+		metricsAddr = "0.0.0.0:" + containerMetricsPort
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Build and run with:\n")
+	fmt.Fprintf(&b, "#   docker build -t conduit-custom .\n")
+	fmt.Fprintf(&b, "#   docker run -d --name conduit -v conduit-data:%s --restart unless-stopped conduit-custom\n", containerDataDir)
+	fmt.Fprintf(&b, "\nFROM %s\n", image(opts))
+	if opts.PsiphonConfigPath != "" {
+		fmt.Fprintf(&b, "\nCOPY %s %s\n", opts.PsiphonConfigPath, containerConfigPath)
+	}
+	if metricsAddr != "" {
+		fmt.Fprintf(&b, "\nEXPOSE %s\n", containerMetricsPort)
+	}
+	fmt.Fprintf(&b, "\nCMD %s\n", jsonArray(startArgs(opts, containerDataDir, metricsAddr, configPath)))
+
+	return b.String(), nil
+}
+
+// Compose returns a docker compose file with one service per instance. Each
+// instance gets its own data volume and, if metrics are enabled, its own
+// host port counting up from the one in MetricsAddr.
+func Compose(opts Options) (string, error) {
+	if err := opts.Validate(); err != nil {
+		return "", err
+	}
+
+	configPath := ""
+	if opts.PsiphonConfigPath != "" {
+		configPath = containerConfigPath
+	}
+	metricsAddr := ""
+	if opts.MetricsAddr != "" {
+		metricsAddr = "0.0.0.0:" + containerMetricsPort
+	}
+
+	var b strings.Builder
+	// Docker can't skip restarts for one exit status, so say what it means
+	fmt.Fprintf(&b, "# Conduit exits with status %d when the Psiphon broker requires a newer\n", config.ExitMustUpgrade)
+	fmt.Fprintf(&b, "# version. Docker restarts it anyway, so pull a newer image when it does.\n")
+	fmt.Fprintf(&b, "services:\n")
+	for i := 1; i <= opts.Instances; i++ {
+		name := composeName(opts, i)
+		fmt.Fprintf(&b, "    %s:\n", name)
+		fmt.Fprintf(&b, "        image: %s\n", image(opts))
+		fmt.Fprintf(&b, "        container_name: %s\n", name)
+		fmt.Fprintf(&b, "        restart: unless-stopped\n")
+		fmt.Fprintf(&b, "        command:\n")
+		fmt.Fprintf(&b, "            %s\n", jsonArray(startArgs(opts, containerDataDir, metricsAddr, configPath)))
+		fmt.Fprintf(&b, "        ulimits:\n")
+		fmt.Fprintf(&b, "            nofile: %d\n", EstimateOpenFiles(opts.MaxClients))
+		if opts.MetricsAddr != "" {
+			host, port, _ := splitMetricsAddr(opts.MetricsAddr)
+			published := strconv.Itoa(port + i - 1)
+			if host != "" {
+				published = net.JoinHostPort(host, published)
+			}
+			fmt.Fprintf(&b, "        ports:\n")
+			fmt.Fprintf(&b, "            - %q\n", published+":"+containerMetricsPort)
+		}
+		fmt.Fprintf(&b, "        volumes:\n")
+		fmt.Fprintf(&b, "            - %s-data:%s\n", name, containerDataDir)
+		if opts.PsiphonConfigPath != "" {
+			fmt.Fprintf(&b, "            - %s:%s:ro\n", opts.PsiphonConfigPath, containerConfigPath)
+		}
+	}
+	fmt.Fprintf(&b, "\nvolumes:\n")
+	for i := 1; i <= opts.Instances; i++ {
+		fmt.Fprintf(&b, "    %s-data:\n", composeName(opts, i))
+	}
+
+	return b.String(), nil
+}
+
+// startArgs returns the conduit start arguments for the deployment
+func startArgs(opts Options, dataDir, metricsAddr, psiphonConfigPath string) []string {
+	args := []string{"start", "--data-dir", dataDir}
+	if psiphonConfigPath != "" {
+		args = append(args, "--psiphon-config", psiphonConfigPath)
+	}
+	if opts.MaxClients > 0 {
+		args = append(args, "--max-clients", strconv.Itoa(opts.MaxClients))
+	}
+	if opts.BandwidthSet {
+		args = append(args, "--bandwidth", strconv.FormatFloat(opts.BandwidthMbps, 'f', -1, 64))
+	}
+	if metricsAddr != "" {
+		args = append(args, "--metrics-addr", metricsAddr)
+	}
+	return args
+}
+
+// splitMetricsAddr splits a metrics address into its (possibly empty) host and port
+func splitMetricsAddr(addr string) (string, int, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid metrics-addr %q: %w", addr, err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 1 || port > 65535 {
+		return "", 0, fmt.Errorf("invalid metrics-addr port %q", portStr)
+	}
+	return host, port, nil
+}
+
+func image(opts Options) string {
+	if opts.Image != "" {
+		return opts.Image
+	}
+	return DefaultImage
+}
+
+func composeName(opts Options, instance int) string {
+	if opts.Instances == 1 {
+		return "conduit"
+	}
+	return fmt.Sprintf("conduit-%d", instance)
+}
+
+// systemdJoin joins a command line for ExecStart, quoting arguments with spaces
+func systemdJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if strings.ContainsAny(arg, " \t\"'") {
+			arg = strconv.Quote(arg)
+		}
+		quoted[i] = arg
+	}
+	return strings.Join(quoted, " ")
+}
+
+// jsonArray formats args as an exec-form array for Dockerfiles and compose
+func jsonArray(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = strconv.Quote(arg)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
@@ -0,0 +1,128 @@
+/*
+ * Copyright (c) 2026, Psiphon Inc.
+ * All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package deploy
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSystemdUnit(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     Options
+		expected []string
+		wantErr  bool
+	}{
+		{
+			name: "single_instance",
+			opts: Options{Instances: 1, MaxClients: 200, BandwidthMbps: 20, BandwidthSet: true, MetricsAddr: "127.0.0.1:9090"},
+			expected: []string{
+				"ExecStart=/usr/local/bin/conduit start --data-dir ${STATE_DIRECTORY} --max-clients 200 --bandwidth 20 --metrics-addr 127.0.0.1:9090\n",
+				"LimitNOFILE=4224\n",
+				"StateDirectory=conduit\n",
+				"Restart=on-failure\n",
+				"RestartPreventExitStatus=3\n",
+				"Type=notify\n",
+			},
+		},
+		{
+			name: "template_for_many_instances",
+			opts: Options{Instances: 3, PsiphonConfigPath: "/etc/conduit/psiphon config.json"},
+			expected: []string{
+				"conduit@.service",
+				"conduit@{1..3}",
+				`--psiphon-config "/etc/conduit/psiphon config.json"`,
+				"StateDirectory=conduit/%i\n",
+			},
+		},
+		{
+			name:    "metrics_with_many_instances",
+			opts:    Options{Instances: 2, MetricsAddr: ":9090"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid_bandwidth",
+			opts:    Options{Instances: 1, BandwidthMbps: 0.5, BandwidthSet: true},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			unit, err := SystemdUnit(test.opts)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got unit:\n%s", unit)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SystemdUnit: %v", err)
+			}
+			for _, want := range test.expected {
+				if !strings.Contains(unit, want) {
+					t.Errorf("unit is missing %q:\n%s", want, unit)
+				}
+			}
+		})
+	}
+}
+
+func TestCompose(t *testing.T) {
+	compose, err := Compose(Options{Instances: 2, MetricsAddr: "127.0.0.1:9100", PsiphonConfigPath: "./psiphon_config.json"})
+	if err != nil {
+		t.Fatalf("Compose: %v", err)
+	}
+
+	expected := []string{
+		"# Conduit exits with status 3 ",
+		"    conduit-1:\n",
+		"    conduit-2:\n",
+		`"127.0.0.1:9100:9090"`,
+		`"127.0.0.1:9101:9090"`,
+		"- conduit-2-data:/home/conduit/data\n",
+		"- ./psiphon_config.json:/home/conduit/psiphon_config.json:ro\n",
+		`"--psiphon-config", "/home/conduit/psiphon_config.json"`,
+		`"--metrics-addr", "0.0.0.0:9090"`,
+	}
+	for _, want := range expected {
+		if !strings.Contains(compose, want) {
+			t.Errorf("compose file is missing %q:\n%s", want, compose)
+		}
+	}
+}
+
+func TestDockerfile(t *testing.T) {
+	dockerfile, err := Dockerfile(Options{Instances: 1, BandwidthMbps: -1, BandwidthSet: true, Image: "conduit:test"})
+	if err != nil {
+		t.Fatalf("Dockerfile: %v", err)
+	}
+	if !strings.Contains(dockerfile, "FROM conduit:test\n") {
+		t.Errorf("Dockerfile does not use the given image:\n%s", dockerfile)
+	}
+	if !strings.Contains(dockerfile, `CMD ["start", "--data-dir", "/home/conduit/data", "--bandwidth", "-1"]`) {
+		t.Errorf("Dockerfile has unexpected CMD:\n%s", dockerfile)
+	}
+
+	if _, err := Dockerfile(Options{Instances: 2}); err == nil {
+		t.Errorf("expected error generating a Dockerfile for more than one instance")
+	}
+}
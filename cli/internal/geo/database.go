@@ -26,6 +26,8 @@ import (
 	"os"
 	"path/filepath"
 	"time"
+
+	"github.com/Psiphon-Inc/conduit/cli/internal/logging"
 )
 
 const (
@@ -45,7 +47,7 @@ func EnsureDatabase(dbPath string) error {
 	}
 
 	// Database doesn't exist, download it
-	fmt.Printf("[GEO] Downloading GeoLite2 database...\n")
+	logging.Printf("[GEO] Downloading GeoLite2 database...\n")
 	return downloadDatabase(dbPath)
 }
 
@@ -63,7 +65,7 @@ func UpdateDatabase(dbPath string) error {
 		return nil
 	}
 
-	fmt.Printf("[GEO] Updating GeoLite2 database...\n")
+	logging.Printf("[GEO] Updating GeoLite2 database...\n")
 
 	// Download to temporary file first
 	tmpPath := dbPath + ".tmp"
@@ -118,6 +120,6 @@ func downloadDatabase(destPath string) error {
 		return fmt.Errorf("failed to write database: %w", err)
 	}
 
-	fmt.Printf("[GEO] Downloaded %d bytes\n", written)
+	logging.Printf("[GEO] Downloaded %d bytes\n", written)
 	return nil
 }
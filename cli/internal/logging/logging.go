@@ -2,15 +2,154 @@ package logging
 
 import (
 	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 const TimeFormat = "2006-01-02 15:04:05"
 
+// summaryInterval is how often counts of suppressed messages are logged
+const summaryInterval = 10 * time.Second
+
+// maxLimitedMessages bounds how many distinct messages are tracked
+const maxLimitedMessages = 1024
+
+// digits matches the numbers that limitKey ignores
+var digits = regexp.MustCompile(`[0-9]+`)
+
+// limiter rate limits each distinct message with its own token bucket, so a
+// flood of one message doesn't hide the others
+type limiter struct {
+	mu      sync.Mutex
+	rate    float64 // Messages per second, per distinct message (0 = unlimited)
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	tokens     float64
+	last       time.Time
+	suppressed int
+	sample     string // The last message suppressed, as printed
+}
+
+var (
+	rateLimit   limiter
+	summaryOnce sync.Once
+	prefix      atomic.Pointer[string] // Inserted after the timestamp, e.g. "[name] "
+)
+
+// SetPrefix tags every following message with name (empty = no tag)
+func SetPrefix(name string) {
+	if name == "" {
+		prefix.Store(nil)
+		return
+	}
+	tag := "[" + name + "] "
+	prefix.Store(&tag)
+}
+
+// header returns the timestamp and tag that start each message
+func header() string {
+	if tag := prefix.Load(); tag != nil {
+		return time.Now().Format(TimeFormat) + " " + *tag
+	}
+	return time.Now().Format(TimeFormat) + " "
+}
+
+// SetRateLimit limits each distinct log message to perSecond messages per
+// second. Suppressed messages are counted and summarized periodically.
+func SetRateLimit(perSecond float64) {
+	rateLimit.mu.Lock()
+	rateLimit.rate = perSecond
+	rateLimit.buckets = make(map[string]*bucket)
+	rateLimit.mu.Unlock()
+
+	if perSecond > 0 {
+		summaryOnce.Do(func() {
+			go func() {
+				for range time.Tick(summaryInterval) {
+					logSuppressed(rateLimit.takeSuppressed())
+				}
+			}()
+		})
+	}
+}
+
+// limitKey returns the key message is limited by. Messages that differ only
+// in their numbers, such as counts, durations and addresses, share a budget.
+func limitKey(message string) string {
+	return digits.ReplaceAllString(message, "0")
+}
+
+// allow reports whether the rendered message may be logged at now
+func (l *limiter) allow(message string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.rate <= 0 {
+		return true
+	}
+
+	burst := max(l.rate, 1)
+	key := limitKey(message)
+	b, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= maxLimitedMessages {
+			l.buckets = make(map[string]*bucket)
+		}
+		b = &bucket{tokens: burst, last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = min(burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens < 1 {
+		b.suppressed++
+		b.sample = message
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// takeSuppressed returns and clears the suppressed count of each message,
+// keyed by the last one suppressed
+func (l *limiter) takeSuppressed() map[string]int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	counts := make(map[string]int)
+	for _, b := range l.buckets {
+		if b.suppressed > 0 {
+			counts[b.sample] = b.suppressed
+			b.suppressed = 0
+		}
+	}
+	return counts
+}
+
+func logSuppressed(counts map[string]int) {
+	for sample, n := range counts {
+		fmt.Printf("%s[INFO] %d similar messages suppressed, e.g. %s\n",
+			header(), n, strings.TrimSpace(sample))
+	}
+}
+
 func Printf(format string, args ...any) {
-	fmt.Printf("%s "+format, append([]any{time.Now().Format(TimeFormat)}, args...)...)
+	message := fmt.Sprintf(format, args...)
+	if !rateLimit.allow(message, time.Now()) {
+		return
+	}
+	fmt.Print(header() + message)
 }
 
 func Println(args ...any) {
-	fmt.Println(append([]any{time.Now().Format(TimeFormat)}, args...)...)
+	message := fmt.Sprintln(args...)
+	if !rateLimit.allow(message, time.Now()) {
+		return
+	}
+	fmt.Print(header() + message)
 }
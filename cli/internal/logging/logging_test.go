@@ -0,0 +1,66 @@
+package logging
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	l := limiter{rate: 2, buckets: make(map[string]*bucket)}
+	start := time.Now()
+
+	// A burst of the rate is allowed, then messages are suppressed
+	allowed := 0
+	for range 5 {
+		if l.allow("reconnecting\n", start) {
+			allowed++
+		}
+	}
+	if allowed != 2 {
+		t.Errorf("allowed %d of a burst of 5, expected 2", allowed)
+	}
+
+	// Other messages have their own budget
+	if !l.allow("other\n", start) {
+		t.Errorf("distinct message was suppressed")
+	}
+
+	// Tokens refill at the rate
+	if !l.allow("reconnecting\n", start.Add(500*time.Millisecond)) {
+		t.Errorf("message suppressed after refill")
+	}
+
+	counts := l.takeSuppressed()
+	if counts["reconnecting\n"] != 3 || len(counts) != 1 {
+		t.Errorf("suppressed counts = %v, expected 3 for one message", counts)
+	}
+	if counts := l.takeSuppressed(); len(counts) != 0 {
+		t.Errorf("suppressed counts not cleared: %v", counts)
+	}
+
+	// Messages from one format are limited separately, but numbers are
+	// ignored, and suppressed messages are summarized by the last one
+	l = limiter{rate: 1, buckets: make(map[string]*bucket)}
+	for _, message := range []string{
+		"[ERROR] dial 10.0.0.1: refused\n",
+		"[ERROR] config: missing SponsorId\n",
+		"[ERROR] dial 10.0.0.2: refused\n",
+	} {
+		l.allow(message, start)
+	}
+	if !l.allow("[ERROR] config: missing PropagationChannelId\n", start) {
+		t.Errorf("an error was suppressed by a different error with the same format")
+	}
+	counts = l.takeSuppressed()
+	if counts["[ERROR] dial 10.0.0.2: refused\n"] != 1 || len(counts) != 1 {
+		t.Errorf("suppressed counts = %v, expected 1 for the second dial error", counts)
+	}
+
+	// A zero rate allows everything
+	unlimited := limiter{}
+	for range 100 {
+		if !unlimited.allow("x", start) {
+			t.Fatalf("unlimited limiter suppressed a message")
+		}
+	}
+}
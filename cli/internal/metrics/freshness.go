@@ -0,0 +1,89 @@
+/*
+ * Copyright (c) 2026, Psiphon Inc.
+ * All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Subsystems reported by conduit_metrics_last_updated_seconds
+const (
+	subsystemActivity  = "activity"   // Client and byte gauges, from tunnel-core activity updates
+	subsystemBroker    = "broker"     // Live, throttle and upgrade state
+	subsystemConfig    = "config"     // Limits and config epoch
+	subsystemGeo       = "geo"        // Per-country metrics
+	subsystemNotice    = "notice"     // Operator notice
+	subsystemStatsFile = "stats_file" // Stats file writes
+	subsystemScrape    = "scrape"     // Computed when scraped: uptime, idle time, Go and process metrics
+)
+
+// freshness reports when each group of metrics was last refreshed. A group
+// appears once it is first updated, so a missing group has not been
+// refreshed since the metrics were created.
+type freshness struct {
+	desc    *prometheus.Desc
+	mu      sync.Mutex
+	updated map[string]time.Time
+}
+
+func newFreshness() *freshness {
+	return &freshness{
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "metrics_last_updated_seconds"),
+			"Unix time when each group of metrics was last refreshed",
+			[]string{"subsystem"}, nil,
+		),
+		updated: make(map[string]time.Time),
+	}
+}
+
+// touch records that subsystem's metrics were refreshed now
+func (f *freshness) touch(subsystem string) {
+	f.mu.Lock()
+	f.updated[subsystem] = time.Now()
+	f.mu.Unlock()
+}
+
+// reset forgets every subsystem, as if the metrics were just created
+func (f *freshness) reset() {
+	f.mu.Lock()
+	clear(f.updated)
+	f.mu.Unlock()
+}
+
+func (f *freshness) Describe(ch chan<- *prometheus.Desc) {
+	ch <- f.desc
+}
+
+func (f *freshness) Collect(ch chan<- prometheus.Metric) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for subsystem, t := range f.updated {
+		ch <- prometheus.MustNewConstMetric(f.desc, prometheus.GaugeValue, unixSeconds(t), subsystem)
+	}
+	ch <- prometheus.MustNewConstMetric(f.desc, prometheus.GaugeValue, unixSeconds(time.Now()), subsystemScrape)
+}
+
+func unixSeconds(t time.Time) float64 {
+	return float64(t.UnixNano()) / 1e9
+}
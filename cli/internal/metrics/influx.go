@@ -0,0 +1,284 @@
+/*
+ * Copyright (c) 2026, Psiphon Inc.
+ * All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Psiphon-Inc/conduit/cli/internal/logging"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// influxMaxPacket bounds the size of each UDP datagram so that writes are
+// not fragmented on common paths
+const influxMaxPacket = 1400
+
+// influxHTTPTimeout bounds each HTTP write
+const influxHTTPTimeout = 10 * time.Second
+
+// InfluxOptions configures pushing metrics in InfluxDB line protocol
+type InfluxOptions struct {
+	Addr     string            // udp://host:port, http(s)://.../write URL, or file:///path
+	Interval time.Duration     // How often metrics are pushed
+	Tags     map[string]string // Added to every line, e.g. instance
+	FileMode os.FileMode       // Exact mode of a file:// output (0 = 0644 less umask)
+}
+
+// WriteInflux writes the exported metrics to w in InfluxDB line protocol,
+// one line per series, with tags added to every line. Each metric is its own
+// measurement: gauges and counters have a value field, histograms and
+// summaries have count, sum and one field per bucket or quantile.
+func (m *Metrics) WriteInflux(w io.Writer, tags map[string]string, now time.Time) error {
+	mfs, err := m.gatherer().Gather()
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	for _, mf := range mfs {
+		for _, metric := range mf.GetMetric() {
+			fields := influxFields(mf.GetType(), metric)
+			if len(fields) == 0 {
+				continue
+			}
+			writeInfluxLine(&buf, mf.GetName(), influxTags(tags, metric.GetLabel()), fields, now)
+		}
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// influxField is one field of a line; values are always floats
+type influxField struct {
+	key   string
+	value float64
+}
+
+func influxFields(metricType dto.MetricType, metric *dto.Metric) []influxField {
+	var fields []influxField
+	add := func(key string, value float64) {
+		// Line protocol has no representation for NaN or infinity
+		if !math.IsNaN(value) && !math.IsInf(value, 0) {
+			fields = append(fields, influxField{key, value})
+		}
+	}
+
+	switch metricType {
+	case dto.MetricType_GAUGE:
+		add("value", metric.GetGauge().GetValue())
+	case dto.MetricType_COUNTER:
+		add("value", metric.GetCounter().GetValue())
+	case dto.MetricType_UNTYPED:
+		add("value", metric.GetUntyped().GetValue())
+	case dto.MetricType_HISTOGRAM:
+		h := metric.GetHistogram()
+		add("count", float64(h.GetSampleCount()))
+		add("sum", h.GetSampleSum())
+		for _, b := range h.GetBucket() {
+			// The +Inf bucket is the same as count
+			if math.IsInf(b.GetUpperBound(), 1) {
+				continue
+			}
+			add(formatInfluxFloat(b.GetUpperBound()), float64(b.GetCumulativeCount()))
+		}
+	case dto.MetricType_SUMMARY:
+		s := metric.GetSummary()
+		add("count", float64(s.GetSampleCount()))
+		add("sum", s.GetSampleSum())
+		for _, q := range s.GetQuantile() {
+			add(formatInfluxFloat(q.GetQuantile()), q.GetValue())
+		}
+	}
+	return fields
+}
+
+// influxTags merges the extra tags with the metric's labels (which win) in
+// key order, as InfluxDB recommends. Empty values are left out since line
+// protocol can't express them.
+func influxTags(extra map[string]string, labels []*dto.LabelPair) [][2]string {
+	merged := make(map[string]string, len(extra)+len(labels))
+	for key, value := range extra {
+		merged[key] = value
+	}
+	for _, label := range labels {
+		merged[label.GetName()] = label.GetValue()
+	}
+
+	tags := make([][2]string, 0, len(merged))
+	for key, value := range merged {
+		if value != "" {
+			tags = append(tags, [2]string{key, value})
+		}
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i][0] < tags[j][0] })
+	return tags
+}
+
+func writeInfluxLine(buf *bytes.Buffer, measurement string, tags [][2]string, fields []influxField, now time.Time) {
+	buf.WriteString(influxMeasurementEscaper.Replace(measurement))
+	for _, tag := range tags {
+		buf.WriteByte(',')
+		buf.WriteString(influxKeyEscaper.Replace(tag[0]))
+		buf.WriteByte('=')
+		buf.WriteString(influxKeyEscaper.Replace(tag[1]))
+	}
+	for i, field := range fields {
+		if i == 0 {
+			buf.WriteByte(' ')
+		} else {
+			buf.WriteByte(',')
+		}
+		buf.WriteString(influxKeyEscaper.Replace(field.key))
+		buf.WriteByte('=')
+		buf.WriteString(formatInfluxFloat(field.value))
+	}
+	buf.WriteByte(' ')
+	buf.WriteString(strconv.FormatInt(now.UnixNano(), 10))
+	buf.WriteByte('\n')
+}
+
+var (
+	influxMeasurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+	influxKeyEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+)
+
+func formatInfluxFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// PushInflux writes the exported metrics to opts.Addr every opts.Interval
+// until ctx is done, then writes them once more so that the final values
+// reach InfluxDB. Failed writes are logged and retried on the next tick.
+func (m *Metrics) PushInflux(ctx context.Context, opts InfluxOptions) {
+	u, err := url.Parse(opts.Addr)
+	if err != nil {
+		logging.Printf("[ERROR] Invalid InfluxDB address %s: %v\n", opts.Addr, err)
+		return
+	}
+	client := &http.Client{Timeout: min(opts.Interval, influxHTTPTimeout)}
+
+	push := func(ctx context.Context, now time.Time) {
+		var buf bytes.Buffer
+		if err := m.WriteInflux(&buf, opts.Tags, now); err != nil {
+			logging.Printf("[WARN] Failed to gather metrics for InfluxDB: %v\n", err)
+			return
+		}
+		if err := sendInflux(ctx, client, u, opts.FileMode, buf.Bytes()); err != nil {
+			logging.Printf("[WARN] Failed to write metrics to InfluxDB: %v\n", err)
+		}
+	}
+
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			finalCtx, cancel := context.WithTimeout(context.Background(), client.Timeout)
+			push(finalCtx, time.Now())
+			cancel()
+			return
+		case now := <-ticker.C:
+			push(ctx, now)
+		}
+	}
+}
+
+// sendInflux delivers one batch of lines to u
+func sendInflux(ctx context.Context, client *http.Client, u *url.URL, mode os.FileMode, data []byte) error {
+	switch u.Scheme {
+	case "udp":
+		conn, err := net.Dial("udp", u.Host)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		// Split on line boundaries so each datagram parses on its own
+		for len(data) > 0 {
+			n := len(data)
+			if n > influxMaxPacket {
+				if i := bytes.LastIndexByte(data[:influxMaxPacket], '\n'); i >= 0 {
+					n = i + 1
+				}
+			}
+			if _, err := conn.Write(data[:n]); err != nil {
+				return err
+			}
+			data = data[n:]
+		}
+		return nil
+
+	case "http", "https":
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		_, _ = io.Copy(io.Discard, resp.Body)
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("%s returned %s", u.Redacted(), resp.Status)
+		}
+		return nil
+
+	case "file":
+		// Each batch replaces the file, so it holds only the latest
+		// snapshot. Renaming into place keeps readers from seeing a
+		// partial one
+		tmpPath := u.Path + ".tmp"
+		perm := os.FileMode(0644)
+		if mode != 0 {
+			perm = mode
+		}
+		if err := os.WriteFile(tmpPath, data, perm); err != nil {
+			return err
+		}
+		if mode != 0 {
+			// WriteFile keeps the mode of an existing file and applies the umask
+			if err := os.Chmod(tmpPath, mode); err != nil {
+				_ = os.Remove(tmpPath)
+				return err
+			}
+		}
+		if err := os.Rename(tmpPath, u.Path); err != nil {
+			_ = os.Remove(tmpPath)
+			return err
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unsupported InfluxDB address scheme %q", u.Scheme)
+	}
+}
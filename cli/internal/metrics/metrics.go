@@ -24,21 +24,30 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
+	"os"
+	"runtime"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/Psiphon-Inc/conduit/cli/internal/geo"
 	"github.com/Psiphon-Inc/conduit/cli/internal/logging"
+	"github.com/Psiphon-Inc/conduit/cli/internal/systemd"
 	"github.com/Psiphon-Labs/psiphon-tunnel-core/psiphon/common/buildinfo"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/common/expfmt"
 )
 
 const namespace = "conduit"
 
+// unixAddrPrefix marks a metrics address as a unix socket path
+const unixAddrPrefix = "unix:"
+
 // Metrics holds all Prometheus metrics for the Conduit service
 type Metrics struct {
 	// Gauges
@@ -50,6 +59,26 @@ type Metrics struct {
 	BandwidthLimit    prometheus.Gauge
 	BytesUploaded     prometheus.Gauge
 	BytesDownloaded   prometheus.Gauge
+	TimeToFirstClient prometheus.Gauge
+	OperatorNotice    prometheus.Gauge
+	ConfigEpoch       prometheus.Gauge
+	PeakClients       *prometheus.GaugeVec
+	BrokerThrottle    prometheus.Gauge
+	BrokerBackoff     prometheus.Gauge
+	MustUpgrade       prometheus.Gauge
+
+	// Counters
+	ConfigReloadFailures prometheus.Counter
+	ForceDroppedClients  prometheus.Counter
+	BrokerTimeouts       prometheus.Counter
+	MemoryPressureEvents prometheus.Counter
+	FDPressureEvents     prometheus.Counter
+	UnhealthyRestarts    prometheus.Counter
+	ConfigFailovers      prometheus.Counter
+	StatsWriteErrors     prometheus.Counter
+
+	// Histograms
+	StatsWriteDuration prometheus.Histogram
 
 	// Geo metrics (by country)
 	geoConnectedClients   *prometheus.GaugeVec
@@ -60,8 +89,11 @@ type Metrics struct {
 	// Info
 	BuildInfo *prometheus.GaugeVec
 
-	registry *prometheus.Registry
-	server   *http.Server
+	registry   *prometheus.Registry
+	server     *http.Server
+	socketPath string // Unix socket to remove on shutdown, if any
+	privacy    bool   // Coarsen exported metrics, see privacyGatherer
+	updated    *freshness
 
 	// State for counter delta tracking
 	geoMu       sync.Mutex
@@ -74,9 +106,14 @@ type GaugeFuncs struct {
 	GetIdleSeconds   func() float64
 }
 
-// New creates a new Metrics instance with all metrics registered
-func New(gaugeFuncs GaugeFuncs) *Metrics {
-	registry := prometheus.NewRegistry()
+// New creates a new Metrics instance with all metrics registered. Every
+// metric carries constLabels (which may be nil), e.g. to tag a deployment.
+// If some metrics conflict with existing registrations, New still returns a
+// usable Metrics (the conflicting metrics are simply not exported) along
+// with an error describing the conflicts.
+func New(gaugeFuncs GaugeFuncs, constLabels prometheus.Labels) (*Metrics, error) {
+	gatherer := prometheus.NewRegistry()
+	registry := prometheus.WrapRegistererWith(constLabels, gatherer)
 
 	// Add standard Go metrics
 	registerCollector(collectors.NewGoCollector(), registry)
@@ -86,123 +123,316 @@ func New(gaugeFuncs GaugeFuncs) *Metrics {
 	)
 
 	m := &Metrics{
-		Announcing: newGauge(
-			prometheus.GaugeOpts{
-				Namespace: namespace,
-				Name:      "announcing",
-				Help:      "Number of inproxy announcement requests in flight",
-			},
-			registry,
-		),
-		ConnectingClients: newGauge(
-			prometheus.GaugeOpts{
-				Namespace: namespace,
-				Name:      "connecting_clients",
-				Help:      "Number of clients currently connecting to the proxy",
-			},
-			registry,
-		),
-		ConnectedClients: newGauge(
-			prometheus.GaugeOpts{
-				Namespace: namespace,
-				Name:      "connected_clients",
-				Help:      "Number of clients currently connected to the proxy",
-			},
-			registry,
-		),
-		IsLive: newGauge(
-			prometheus.GaugeOpts{
-				Namespace: namespace,
-				Name:      "is_live",
-				Help:      "Whether the service is connected to the Psiphon broker (1 = connected, 0 = disconnected)",
-			},
-			registry,
-		),
-		MaxClients: newGauge(
-			prometheus.GaugeOpts{
-				Namespace: namespace,
-				Name:      "max_clients",
-				Help:      "Maximum number of proxy clients allowed",
-			},
-			registry,
-		),
-		BandwidthLimit: newGauge(
-			prometheus.GaugeOpts{
-				Namespace: namespace,
-				Name:      "bandwidth_limit_bytes_per_second",
-				Help:      "Configured bandwidth limit in bytes per second (0 = unlimited)",
-			},
-			registry,
-		),
-		BytesUploaded: newGauge(
-			prometheus.GaugeOpts{
-				Namespace: namespace,
-				Name:      "bytes_uploaded",
-				Help:      "Total number of bytes uploaded through the proxy",
-			},
-			registry,
-		),
-		BytesDownloaded: newGauge(
-			prometheus.GaugeOpts{
-				Namespace: namespace,
-				Name:      "bytes_downloaded",
-				Help:      "Total number of bytes downloaded through the proxy",
-			},
-			registry,
-		),
-		geoConnectedClients: newGaugeVec(
-			prometheus.GaugeOpts{
-				Namespace: namespace,
-				Name:      "geo_connected_clients",
-				Help:      "Number of currently connected clients by country",
-			},
-			[]string{"country_code"},
-			registry,
-		),
-		geoTotalClients: newCounterVec(
-			prometheus.CounterOpts{
-				Namespace: namespace,
-				Name:      "geo_clients_total",
-				Help:      "Total unique clients by country since start",
-			},
-			[]string{"country_code"},
-			registry,
-		),
-		geoBytesUploadedVec: newCounterVec(
-			prometheus.CounterOpts{
-				Namespace: namespace,
-				Name:      "geo_bytes_uploaded_total",
-				Help:      "Total bytes uploaded by country",
-			},
-			[]string{"country_code"},
-			registry,
-		),
-		geoBytesDownloadedVec: newCounterVec(
-			prometheus.CounterOpts{
-				Namespace: namespace,
-				Name:      "geo_bytes_downloaded_total",
-				Help:      "Total bytes downloaded by country",
-			},
-			[]string{"country_code"},
-			registry,
-		),
-		BuildInfo: newGaugeVec(
-			prometheus.GaugeOpts{
-				Namespace: namespace,
-				Name:      "build_info",
-				Help:      "Build information about the Conduit service",
-			},
-			[]string{"build_repo", "build_rev", "go_version", "values_rev"},
-			registry,
-		),
-
-		// Internal state
 		geoPrevious: make(map[string]geo.Result),
-		registry:    registry,
+		registry:    gatherer,
+		updated:     newFreshness(),
 	}
+	registerCollector(m.updated, registry)
+
+	var err error
+	var errs []error
+
+	m.Announcing, err = newGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "announcing",
+			Help:      "Number of inproxy announcement requests in flight",
+		},
+		registry,
+	)
+	errs = appendError(errs, err)
+
+	m.ConnectingClients, err = newGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "connecting_clients",
+			Help:      "Number of clients currently connecting to the proxy",
+		},
+		registry,
+	)
+	errs = appendError(errs, err)
+
+	m.ConnectedClients, err = newGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "connected_clients",
+			Help:      "Number of clients currently connected to the proxy",
+		},
+		registry,
+	)
+	errs = appendError(errs, err)
+
+	m.IsLive, err = newGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "is_live",
+			Help:      "Whether the service is connected to the Psiphon broker (1 = connected, 0 = disconnected)",
+		},
+		registry,
+	)
+	errs = appendError(errs, err)
+
+	m.TimeToFirstClient, err = newGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "time_to_first_client_seconds",
+			Help:      "Seconds from going live with the broker to the first connected client (0 = no client yet)",
+		},
+		registry,
+	)
+	errs = appendError(errs, err)
+
+	m.OperatorNotice, err = newGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "operator_notice",
+			Help:      "Whether a signed operator notice is currently published (1 = yes)",
+		},
+		registry,
+	)
+	errs = appendError(errs, err)
+
+	m.ConfigEpoch, err = newGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "config_epoch",
+			Help:      "Number of configurations applied since the process started, counting the first (reloads and failovers each add one)",
+		},
+		registry,
+	)
+	errs = appendError(errs, err)
+
+	m.PeakClients, err = newGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "peak_clients",
+			Help:      "Most clients connected at once, since the service started (since=\"start\") or since the peak was last reset (since=\"reset\")",
+		},
+		[]string{"since"},
+		registry,
+	)
+	errs = appendError(errs, err)
+	m.PeakClients.WithLabelValues("start").Set(0)
+	m.PeakClients.WithLabelValues("reset").Set(0)
+
+	m.BrokerThrottle, err = newGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "broker_throttle_active",
+			Help:      "Whether the broker has asked this proxy to back off and no client has been matched since (1 = backing off)",
+		},
+		registry,
+	)
+	errs = appendError(errs, err)
+
+	m.BrokerBackoff, err = newGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "broker_throttle_backoff_seconds",
+			Help:      "Delay before the next announcement when the broker last asked this proxy to back off (0 when not backing off)",
+		},
+		registry,
+	)
+	errs = appendError(errs, err)
+
+	m.MustUpgrade, err = newGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "upgrade_required",
+			Help:      "Whether the broker has rejected this version of conduit (1 = upgrade required)",
+		},
+		registry,
+	)
+	errs = appendError(errs, err)
+
+	m.MaxClients, err = newGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "max_clients",
+			Help:      "Maximum number of proxy clients allowed",
+		},
+		registry,
+	)
+	errs = appendError(errs, err)
+
+	m.BandwidthLimit, err = newGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "bandwidth_limit_bytes_per_second",
+			Help:      "Configured bandwidth limit in bytes per second (0 = unlimited)",
+		},
+		registry,
+	)
+	errs = appendError(errs, err)
+
+	m.BytesUploaded, err = newGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "bytes_uploaded",
+			Help:      "Total number of bytes uploaded through the proxy",
+		},
+		registry,
+	)
+	errs = appendError(errs, err)
+
+	m.BytesDownloaded, err = newGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "bytes_downloaded",
+			Help:      "Total number of bytes downloaded through the proxy",
+		},
+		registry,
+	)
+	errs = appendError(errs, err)
+
+	m.ConfigReloadFailures, err = newCounter(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "config_reload_failures_total",
+			Help:      "Total number of configuration reloads rejected because the new config was invalid",
+		},
+		registry,
+	)
+	errs = appendError(errs, err)
+
+	m.ForceDroppedClients, err = newCounter(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "force_dropped_clients_total",
+			Help:      "Total number of connected clients whose sessions were cut off when the service stopped",
+		},
+		registry,
+	)
+	errs = appendError(errs, err)
+
+	m.BrokerTimeouts, err = newCounter(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "broker_request_timeouts_total",
+			Help:      "Total number of broker requests reported as timed out (tunnel-core samples these reports)",
+		},
+		registry,
+	)
+	errs = appendError(errs, err)
+
+	m.MemoryPressureEvents, err = newCounter(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "memory_pressure_events_total",
+			Help:      "Total number of times memory use crossed the memory pressure threshold",
+		},
+		registry,
+	)
+	errs = appendError(errs, err)
+
+	m.FDPressureEvents, err = newCounter(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "fd_pressure_events_total",
+			Help:      "Total number of times open file descriptors crossed the pressure fraction of the soft limit",
+		},
+		registry,
+	)
+	errs = appendError(errs, err)
+
+	m.UnhealthyRestarts, err = newCounter(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "unhealthy_restarts_total",
+			Help:      "Total number of restarts because the service did not go live with the broker in time",
+		},
+		registry,
+	)
+	errs = appendError(errs, err)
+
+	m.ConfigFailovers, err = newCounter(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "config_failover_total",
+			Help:      "Total number of switches to the next psiphon config because the current one did not go live",
+		},
+		registry,
+	)
+	errs = appendError(errs, err)
+
+	m.StatsWriteErrors, err = newCounter(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "stats_write_errors_total",
+			Help:      "Total number of failed writes of the stats file",
+		},
+		registry,
+	)
+	errs = appendError(errs, err)
+
+	m.StatsWriteDuration, err = newHistogram(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "stats_write_duration_seconds",
+			Help:      "Time taken to write the stats file",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 4, 7), // 1ms to ~4s
+		},
+		registry,
+	)
+	errs = appendError(errs, err)
+
+	m.geoConnectedClients, err = newGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "geo_connected_clients",
+			Help:      "Number of currently connected clients by country",
+		},
+		[]string{"country_code"},
+		registry,
+	)
+	errs = appendError(errs, err)
+
+	m.geoTotalClients, err = newCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "geo_clients_total",
+			Help:      "Total unique clients by country since start",
+		},
+		[]string{"country_code"},
+		registry,
+	)
+	errs = appendError(errs, err)
+
+	m.geoBytesUploadedVec, err = newCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "geo_bytes_uploaded_total",
+			Help:      "Total bytes uploaded by country",
+		},
+		[]string{"country_code"},
+		registry,
+	)
+	errs = appendError(errs, err)
+
+	m.geoBytesDownloadedVec, err = newCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "geo_bytes_downloaded_total",
+			Help:      "Total bytes downloaded by country",
+		},
+		[]string{"country_code"},
+		registry,
+	)
+	errs = appendError(errs, err)
+
+	m.BuildInfo, err = newGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "build_info",
+			Help:      "Build information about the Conduit service",
+		},
+		[]string{"build_repo", "build_rev", "go_version", "values_rev"},
+		registry,
+	)
+	errs = appendError(errs, err)
 
 	// Create GaugeFunc metrics (computed at scrape time)
-	newGaugeFunc(
+	_, err = newGaugeFunc(
 		prometheus.GaugeOpts{
 			Namespace: namespace,
 			Name:      "uptime_seconds",
@@ -211,7 +441,9 @@ func New(gaugeFuncs GaugeFuncs) *Metrics {
 		gaugeFuncs.GetUptimeSeconds,
 		registry,
 	)
-	newGaugeFunc(
+	errs = appendError(errs, err)
+
+	_, err = newGaugeFunc(
 		prometheus.GaugeOpts{
 			Namespace: namespace,
 			Name:      "idle_seconds",
@@ -220,6 +452,18 @@ func New(gaugeFuncs GaugeFuncs) *Metrics {
 		gaugeFuncs.GetIdleSeconds,
 		registry,
 	)
+	errs = appendError(errs, err)
+
+	_, err = newGaugeFunc(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "goroutines",
+			Help:      "Number of goroutines, including those relaying client traffic",
+		},
+		func() float64 { return float64(runtime.NumGoroutine()) },
+		registry,
+	)
+	errs = appendError(errs, err)
 
 	// Set build info
 	buildInfo := buildinfo.GetBuildInfo()
@@ -231,28 +475,40 @@ func New(gaugeFuncs GaugeFuncs) *Metrics {
 			buildInfo.ValuesRev).
 		Set(1)
 
-	return m
+	return m, errors.Join(errs...)
+}
+
+// appendError appends err to errs if it is non-nil
+func appendError(errs []error, err error) []error {
+	if err != nil {
+		return append(errs, err)
+	}
+	return errs
 }
 
 // SetConfig sets the configuration-related metrics
 func (m *Metrics) SetConfig(maxClients int, bandwidthBytesPerSecond int) {
 	m.MaxClients.Set(float64(maxClients))
 	m.BandwidthLimit.Set(float64(bandwidthBytesPerSecond))
+	m.updated.touch(subsystemConfig)
 }
 
 // SetAnnouncing updates the announcing gauge
 func (m *Metrics) SetAnnouncing(count int) {
 	m.Announcing.Set(float64(count))
+	m.updated.touch(subsystemActivity)
 }
 
 // SetConnectingClients updates the connecting clients gauge
 func (m *Metrics) SetConnectingClients(count int) {
 	m.ConnectingClients.Set(float64(count))
+	m.updated.touch(subsystemActivity)
 }
 
 // SetConnectedClients updates the connected clients gauge
 func (m *Metrics) SetConnectedClients(count int) {
 	m.ConnectedClients.Set(float64(count))
+	m.updated.touch(subsystemActivity)
 }
 
 // SetIsLive updates the live status gauge
@@ -262,16 +518,139 @@ func (m *Metrics) SetIsLive(isLive bool) {
 	} else {
 		m.IsLive.Set(0)
 	}
+	m.updated.touch(subsystemBroker)
+}
+
+// SetTimeToFirstClient sets the time to first client gauge
+func (m *Metrics) SetTimeToFirstClient(d time.Duration) {
+	m.TimeToFirstClient.Set(d.Seconds())
+	m.updated.touch(subsystemBroker)
+}
+
+// SetOperatorNotice sets whether an operator notice is published
+func (m *Metrics) SetOperatorNotice(active bool) {
+	if active {
+		m.OperatorNotice.Set(1)
+	} else {
+		m.OperatorNotice.Set(0)
+	}
+	m.updated.touch(subsystemNotice)
+}
+
+// SetConfigEpoch sets the config epoch gauge
+func (m *Metrics) SetConfigEpoch(epoch int) {
+	m.ConfigEpoch.Set(float64(epoch))
+	m.updated.touch(subsystemConfig)
+}
+
+// SetPeakClients sets the peak clients gauges
+func (m *Metrics) SetPeakClients(sinceStart, sinceReset int) {
+	m.PeakClients.WithLabelValues("start").Set(float64(sinceStart))
+	m.PeakClients.WithLabelValues("reset").Set(float64(sinceReset))
+	m.updated.touch(subsystemActivity)
+}
+
+// SetBrokerThrottle sets the broker throttle gauges
+func (m *Metrics) SetBrokerThrottle(active bool, backoff time.Duration) {
+	if active {
+		m.BrokerThrottle.Set(1)
+	} else {
+		m.BrokerThrottle.Set(0)
+	}
+	m.BrokerBackoff.Set(backoff.Seconds())
+	m.updated.touch(subsystemBroker)
+}
+
+// SetMustUpgrade sets whether the broker requires a newer conduit
+func (m *Metrics) SetMustUpgrade(required bool) {
+	if required {
+		m.MustUpgrade.Set(1)
+	} else {
+		m.MustUpgrade.Set(0)
+	}
+	m.updated.touch(subsystemBroker)
 }
 
 // SetBytesUploaded sets the bytes uploaded gauge
 func (m *Metrics) SetBytesUploaded(bytes float64) {
 	m.BytesUploaded.Set(bytes)
+	m.updated.touch(subsystemActivity)
 }
 
 // SetBytesDownloaded sets the bytes downloaded gauge
 func (m *Metrics) SetBytesDownloaded(bytes float64) {
 	m.BytesDownloaded.Set(bytes)
+	m.updated.touch(subsystemActivity)
+}
+
+// IncConfigReloadFailures records a rejected configuration reload
+func (m *Metrics) IncConfigReloadFailures() {
+	m.ConfigReloadFailures.Inc()
+}
+
+// AddForceDroppedClients records clients that were still connected when the service stopped
+func (m *Metrics) AddForceDroppedClients(count int) {
+	m.ForceDroppedClients.Add(float64(count))
+}
+
+// IncBrokerTimeouts records a broker request that timed out
+func (m *Metrics) IncBrokerTimeouts() {
+	m.BrokerTimeouts.Inc()
+}
+
+// IncMemoryPressureEvents records memory use crossing the pressure threshold
+func (m *Metrics) IncMemoryPressureEvents() {
+	m.MemoryPressureEvents.Inc()
+}
+
+// IncFDPressureEvents records a rise past the file descriptor pressure threshold
+func (m *Metrics) IncFDPressureEvents() {
+	m.FDPressureEvents.Inc()
+}
+
+// IncConfigFailovers records a switch to the next psiphon config
+func (m *Metrics) IncConfigFailovers() {
+	m.ConfigFailovers.Inc()
+}
+
+// ObserveStatsWrite records one write of the stats file and whether it failed
+func (m *Metrics) ObserveStatsWrite(d time.Duration, err error) {
+	m.StatsWriteDuration.Observe(d.Seconds())
+	if err != nil {
+		m.StatsWriteErrors.Inc()
+	}
+	m.updated.touch(subsystemStatsFile)
+}
+
+// IncUnhealthyRestarts records a restart of a service that did not go live
+func (m *Metrics) IncUnhealthyRestarts() {
+	m.UnhealthyRestarts.Inc()
+}
+
+// ResetService clears the gauges that describe one run of the service, so
+// that they start empty after a restart or reload like the service's own
+// counts. Counters and histograms carry on across runs.
+func (m *Metrics) ResetService() {
+	m.Announcing.Set(0)
+	m.ConnectingClients.Set(0)
+	m.ConnectedClients.Set(0)
+	m.IsLive.Set(0)
+	m.BytesUploaded.Set(0)
+	m.BytesDownloaded.Set(0)
+	m.TimeToFirstClient.Set(0)
+	m.OperatorNotice.Set(0)
+	m.PeakClients.Reset()
+	m.BrokerThrottle.Set(0)
+	m.BrokerBackoff.Set(0)
+	m.MustUpgrade.Set(0)
+
+	// The new service's geo totals start again from zero
+	m.geoMu.Lock()
+	m.geoConnectedClients.Reset()
+	clear(m.geoPrevious)
+	m.geoMu.Unlock()
+
+	m.updated.reset()
 }
 
 // UpdateGeo updates geo-based metrics from the latest geo collector results.
@@ -298,14 +677,50 @@ func (m *Metrics) UpdateGeo(results []geo.Result) {
 		}
 		m.geoPrevious[r.Code] = r
 	}
+	m.updated.touch(subsystemGeo)
+}
+
+// EnablePrivacy coarsens the exported metrics so that individual clients
+// can't be picked out
+func (m *Metrics) EnablePrivacy() {
+	m.privacy = true
+}
+
+// gatherer returns the source of the exported metrics
+func (m *Metrics) gatherer() prometheus.Gatherer {
+	if m.privacy {
+		return privacyGatherer{m.registry}
+	}
+	return m.registry
+}
+
+// WriteText writes the exported metrics to w in the Prometheus text format,
+// as served by /metrics to scrapers that don't ask for OpenMetrics
+func (m *Metrics) WriteText(w io.Writer) error {
+	mfs, err := m.gatherer().Gather()
+	if err != nil {
+		return err
+	}
+	encoder := expfmt.NewEncoder(w, expfmt.NewFormat(expfmt.TypeTextPlain))
+	for _, mf := range mfs {
+		if err := encoder.Encode(mf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Handler returns the /metrics handler, for serving metrics on another server
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.gatherer(), promhttp.HandlerOpts{
+		EnableOpenMetrics: true,
+	})
 }
 
 // StartServer starts the HTTP server for Prometheus metrics
 func (m *Metrics) StartServer(addr string) error {
 	mux := http.NewServeMux()
-	mux.Handle("/metrics", promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{
-		EnableOpenMetrics: true,
-	}))
+	mux.Handle("/metrics", m.Handler())
 
 	m.server = &http.Server{
 		Addr:         addr,
@@ -316,10 +731,25 @@ func (m *Metrics) StartServer(addr string) error {
 		TLSConfig:    nil,
 	}
 
-	// Create a listener to verify the port is available before starting the server
-	listener, err := net.Listen("tcp", addr)
+	// Prefer a socket passed in by systemd socket activation
+	listener, err := systemd.Listener(systemd.MetricsSocketName)
 	if err != nil {
-		return fmt.Errorf("failed to bind to %s: %w", addr, err)
+		return err
+	}
+	if listener != nil {
+		logging.Printf("[OK] Using socket-activated metrics listener %s\n", listener.Addr())
+	} else if path, ok := UnixSocketPath(addr); ok {
+		listener, err = listenUnix(path)
+		if err != nil {
+			return err
+		}
+		m.socketPath = path
+	} else {
+		// Create a listener to verify the port is available before starting the server
+		listener, err = net.Listen("tcp", addr)
+		if err != nil {
+			return fmt.Errorf("failed to bind to %s: %w", addr, err)
+		}
 	}
 
 	// Start server in background with the pre-created listener
@@ -332,11 +762,53 @@ func (m *Metrics) StartServer(addr string) error {
 	return nil
 }
 
+// UnixSocketPath returns the socket path of a "unix:/path" metrics address
+func UnixSocketPath(addr string) (string, bool) {
+	return strings.CutPrefix(addr, unixAddrPrefix)
+}
+
+// listenUnix listens on a unix socket at path that only the owner and group
+// can connect to, so a local scrape agent can be given access by group
+func listenUnix(path string) (net.Listener, error) {
+	if path == "" {
+		return nil, fmt.Errorf("metrics socket path is empty")
+	}
+
+	// Remove a stale socket left behind by an unclean exit, but never
+	// clobber a regular file the user pointed us at by mistake
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("metrics socket path %s exists and is not a socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("failed to remove stale metrics socket: %w", err)
+		}
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+	if err := os.Chmod(path, 0660); err != nil {
+		_ = listener.Close()
+		return nil, fmt.Errorf("failed to set metrics socket permissions: %w", err)
+	}
+
+	return listener, nil
+}
+
 // Shutdown gracefully shuts down the metrics server
 func (m *Metrics) Shutdown(ctx context.Context) error {
-	if m.server != nil {
-		return m.server.Shutdown(ctx)
+	if m.server == nil {
+		return nil
 	}
 
-	return nil
+	err := m.server.Shutdown(ctx)
+	if m.socketPath != "" {
+		if rmErr := os.Remove(m.socketPath); rmErr != nil && !errors.Is(rmErr, os.ErrNotExist) && err == nil {
+			err = rmErr
+		}
+	}
+
+	return err
 }
@@ -0,0 +1,142 @@
+/*
+ * Copyright (c) 2026, Psiphon Inc.
+ * All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package metrics
+
+import (
+	"math"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Privacy mode thresholds
+const (
+	// privacyMinClients is how many clients a country must have served to
+	// get its own series; smaller countries are merged into privacyOtherCode
+	privacyMinClients = 10
+
+	// privacyRounding is the multiple client counts are rounded to
+	privacyRounding = 5
+
+	privacyOtherCode = "OTHER"
+)
+
+// privacyRoundedMetrics are the client count metrics rounded in privacy mode
+var privacyRoundedMetrics = map[string]bool{
+	namespace + "_connecting_clients":    true,
+	namespace + "_connected_clients":     true,
+	namespace + "_peak_clients":          true,
+	namespace + "_geo_connected_clients": true,
+}
+
+// privacyGatherer coarsens gathered metrics so that they can't be used to
+// pick out individual clients: countries with few clients are merged, and
+// client counts are rounded
+type privacyGatherer struct {
+	prometheus.Gatherer
+}
+
+func (g privacyGatherer) Gather() ([]*dto.MetricFamily, error) {
+	mfs, err := g.Gatherer.Gather()
+
+	// Countries are judged by how many clients they have served in total,
+	// so that a country's series doesn't come and go with each client
+	small := make(map[string]bool)
+	for _, mf := range mfs {
+		if mf.GetName() != namespace+"_geo_clients_total" {
+			continue
+		}
+		for _, metric := range mf.GetMetric() {
+			if metricValue(metric) < privacyMinClients {
+				small[labelValue(metric, "country_code")] = true
+			}
+		}
+	}
+
+	for _, mf := range mfs {
+		if strings.HasPrefix(mf.GetName(), namespace+"_geo_") {
+			mf.Metric = mergeCountries(mf.GetMetric(), small)
+		}
+		if privacyRoundedMetrics[mf.GetName()] {
+			for _, metric := range mf.GetMetric() {
+				setMetricValue(metric, math.Round(metricValue(metric)/privacyRounding)*privacyRounding)
+			}
+		}
+	}
+
+	return mfs, err
+}
+
+// mergeCountries sums the series of the small countries into one series
+// labelled privacyOtherCode
+func mergeCountries(metrics []*dto.Metric, small map[string]bool) []*dto.Metric {
+	var merged []*dto.Metric
+	var other *dto.Metric
+	for _, metric := range metrics {
+		code := labelValue(metric, "country_code")
+		if !small[code] {
+			merged = append(merged, metric)
+			continue
+		}
+		if other == nil {
+			other = metric
+			for _, label := range other.GetLabel() {
+				if label.GetName() == "country_code" {
+					label.Value = stringPtr(privacyOtherCode)
+				}
+			}
+			merged = append(merged, other)
+			continue
+		}
+		setMetricValue(other, metricValue(other)+metricValue(metric))
+	}
+	return merged
+}
+
+func labelValue(metric *dto.Metric, name string) string {
+	for _, label := range metric.GetLabel() {
+		if label.GetName() == name {
+			return label.GetValue()
+		}
+	}
+	return ""
+}
+
+// metricValue returns the value of a gauge or counter
+func metricValue(metric *dto.Metric) float64 {
+	if metric.Gauge != nil {
+		return metric.Gauge.GetValue()
+	}
+	return metric.GetCounter().GetValue()
+}
+
+// setMetricValue sets the value of a gauge or counter
+func setMetricValue(metric *dto.Metric, value float64) {
+	if metric.Gauge != nil {
+		metric.Gauge.Value = &value
+	} else if metric.Counter != nil {
+		metric.Counter.Value = &value
+	}
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
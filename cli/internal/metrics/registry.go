@@ -2,31 +2,39 @@ package metrics
 
 import (
 	"errors"
+	"fmt"
 
 	"github.com/prometheus/client_golang/prometheus"
 )
 
+// Registration helpers return an error when a metric with the same name but
+// a different type is already registered; the metric they return is still
+// safe to update but is not exported. Any other registration error means the
+// metric definition itself is invalid (bad name, inconsistent labels), which
+// is a programmer error and panics.
+
 // build and register a new Prometheus gauge by accepting its options.
 func newGauge(
 	gaugeOpts prometheus.GaugeOpts,
-	registry *prometheus.Registry,
-) prometheus.Gauge {
+	registry prometheus.Registerer,
+) (prometheus.Gauge, error) {
 	ev := prometheus.NewGauge(gaugeOpts)
 
 	err := registry.Register(ev)
 	if err != nil {
 		var are prometheus.AlreadyRegisteredError
 		if ok := errors.As(err, &are); ok {
-			ev, ok = are.ExistingCollector.(prometheus.Gauge)
+			existing, ok := are.ExistingCollector.(prometheus.Gauge)
 			if !ok {
-				panic("different metric type registration")
+				return ev, conflictError(gaugeOpts.Namespace, gaugeOpts.Name)
 			}
+			ev = existing
 		} else {
 			panic(err)
 		}
 	}
 
-	return ev
+	return ev, nil
 }
 
 // build and register a new Prometheus gauge vector by accepting its
@@ -34,24 +42,25 @@ func newGauge(
 func newGaugeVec(
 	gaugeOpts prometheus.GaugeOpts,
 	labels []string,
-	registry *prometheus.Registry,
-) *prometheus.GaugeVec {
+	registry prometheus.Registerer,
+) (*prometheus.GaugeVec, error) {
 	ev := prometheus.NewGaugeVec(gaugeOpts, labels)
 
 	err := registry.Register(ev)
 	if err != nil {
 		var are prometheus.AlreadyRegisteredError
 		if ok := errors.As(err, &are); ok {
-			ev, ok = are.ExistingCollector.(*prometheus.GaugeVec)
+			existing, ok := are.ExistingCollector.(*prometheus.GaugeVec)
 			if !ok {
-				panic("different metric type registration")
+				return ev, conflictError(gaugeOpts.Namespace, gaugeOpts.Name)
 			}
+			ev = existing
 		} else {
 			panic(err)
 		}
 	}
 
-	return ev
+	return ev, nil
 }
 
 // build and register a new Prometheus gauge function by accepting
@@ -59,24 +68,49 @@ func newGaugeVec(
 func newGaugeFunc(
 	gaugeOpts prometheus.GaugeOpts,
 	function func() float64,
-	registry *prometheus.Registry,
-) prometheus.GaugeFunc {
+	registry prometheus.Registerer,
+) (prometheus.GaugeFunc, error) {
 	ev := prometheus.NewGaugeFunc(gaugeOpts, function)
 
 	err := registry.Register(ev)
 	if err != nil {
 		var are prometheus.AlreadyRegisteredError
 		if ok := errors.As(err, &are); ok {
-			ev, ok = are.ExistingCollector.(prometheus.GaugeFunc)
+			existing, ok := are.ExistingCollector.(prometheus.GaugeFunc)
+			if !ok {
+				return ev, conflictError(gaugeOpts.Namespace, gaugeOpts.Name)
+			}
+			ev = existing
+		} else {
+			panic(err)
+		}
+	}
+
+	return ev, nil
+}
+
+// build and register a new Prometheus counter by accepting its options.
+func newCounter(
+	counterOpts prometheus.CounterOpts,
+	registry prometheus.Registerer,
+) (prometheus.Counter, error) {
+	ev := prometheus.NewCounter(counterOpts)
+
+	err := registry.Register(ev)
+	if err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if ok := errors.As(err, &are); ok {
+			existing, ok := are.ExistingCollector.(prometheus.Counter)
 			if !ok {
-				panic("different metric type registration")
+				return ev, conflictError(counterOpts.Namespace, counterOpts.Name)
 			}
+			ev = existing
 		} else {
 			panic(err)
 		}
 	}
 
-	return ev
+	return ev, nil
 }
 
 // build and register a new Prometheus counter vector by accepting its
@@ -84,30 +118,55 @@ func newGaugeFunc(
 func newCounterVec(
 	counterOpts prometheus.CounterOpts,
 	labels []string,
-	registry *prometheus.Registry,
-) *prometheus.CounterVec {
+	registry prometheus.Registerer,
+) (*prometheus.CounterVec, error) {
 	ev := prometheus.NewCounterVec(counterOpts, labels)
 
 	err := registry.Register(ev)
 	if err != nil {
 		var are prometheus.AlreadyRegisteredError
 		if ok := errors.As(err, &are); ok {
-			ev, ok = are.ExistingCollector.(*prometheus.CounterVec)
+			existing, ok := are.ExistingCollector.(*prometheus.CounterVec)
 			if !ok {
-				panic("different metric type registration")
+				return ev, conflictError(counterOpts.Namespace, counterOpts.Name)
 			}
+			ev = existing
 		} else {
 			panic(err)
 		}
 	}
 
-	return ev
+	return ev, nil
+}
+
+// build and register a new Prometheus histogram by accepting its options.
+func newHistogram(
+	histogramOpts prometheus.HistogramOpts,
+	registry prometheus.Registerer,
+) (prometheus.Histogram, error) {
+	ev := prometheus.NewHistogram(histogramOpts)
+
+	err := registry.Register(ev)
+	if err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if ok := errors.As(err, &are); ok {
+			existing, ok := are.ExistingCollector.(prometheus.Histogram)
+			if !ok {
+				return ev, conflictError(histogramOpts.Namespace, histogramOpts.Name)
+			}
+			ev = existing
+		} else {
+			panic(err)
+		}
+	}
+
+	return ev, nil
 }
 
 // registers or reuses a collector without crashing.
 func registerCollector(
 	ct prometheus.Collector,
-	registry *prometheus.Registry,
+	registry prometheus.Registerer,
 ) {
 	if err := registry.Register(ct); err != nil {
 		var are prometheus.AlreadyRegisteredError
@@ -117,3 +176,9 @@ func registerCollector(
 		panic(err)
 	}
 }
+
+// conflictError reports a metric that could not be registered because a
+// metric of a different type already uses its name.
+func conflictError(namespace, name string) error {
+	return fmt.Errorf("metric %s registered with a different type", prometheus.BuildFQName(namespace, "", name))
+}
@@ -20,7 +20,22 @@
 package metrics
 
 import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/Psiphon-Inc/conduit/cli/internal/geo"
+	"github.com/klauspost/compress/snappy"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 // TestRegistryWiring create a new metrics and calls gather to verify
@@ -28,10 +43,13 @@ import (
 // not empty.
 func TestRegistryWiring(t *testing.T) {
 	// fake gauge functions
-	m := New(GaugeFuncs{
+	m, err := New(GaugeFuncs{
 		GetUptimeSeconds: func() float64 { return 123 },
 		GetIdleSeconds:   func() float64 { return 0 },
-	})
+	}, nil)
+	if err != nil {
+		t.Fatalf("unexpected registration error: %v", err)
+	}
 
 	// gather registry metrics
 	mfs, err := m.registry.Gather()
@@ -56,6 +74,11 @@ func TestRegistryWiring(t *testing.T) {
 		"conduit_connecting_clients",
 		"conduit_connected_clients",
 		"conduit_is_live",
+		"conduit_time_to_first_client_seconds",
+		"conduit_operator_notice",
+		"conduit_goroutines",
+		"conduit_stats_write_errors_total",
+		"conduit_stats_write_duration_seconds",
 		"conduit_max_clients",
 		"conduit_bandwidth_limit_bytes_per_second",
 		"conduit_bytes_uploaded",
@@ -63,6 +86,19 @@ func TestRegistryWiring(t *testing.T) {
 		"conduit_build_info",
 		"conduit_uptime_seconds",
 		"conduit_idle_seconds",
+		"conduit_config_reload_failures_total",
+		"conduit_force_dropped_clients_total",
+		"conduit_broker_request_timeouts_total",
+		"conduit_memory_pressure_events_total",
+		"conduit_fd_pressure_events_total",
+		"conduit_unhealthy_restarts_total",
+		"conduit_config_failover_total",
+		"conduit_config_epoch",
+		"conduit_peak_clients",
+		"conduit_broker_throttle_active",
+		"conduit_broker_throttle_backoff_seconds",
+		"conduit_upgrade_required",
+		"conduit_metrics_last_updated_seconds",
 	}
 
 	for _, name := range expected {
@@ -71,3 +107,341 @@ func TestRegistryWiring(t *testing.T) {
 		}
 	}
 }
+
+// TestRegistrationConflict verifies that registering a metric whose name is
+// already taken by a different type returns an error instead of panicking,
+// and that the returned metric can still be used.
+func TestRegistrationConflict(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	opts := prometheus.GaugeOpts{Namespace: namespace, Name: "conflict"}
+
+	if _, err := newCounter(prometheus.CounterOpts(opts), registry); err != nil {
+		t.Fatalf("newCounter: %v", err)
+	}
+
+	gauge, err := newGauge(opts, registry)
+	if err == nil {
+		t.Fatalf("expected conflict error registering gauge over counter")
+	}
+	if gauge == nil {
+		t.Fatalf("expected a usable gauge despite the conflict")
+	}
+	gauge.Set(1)
+
+	// Re-registering the same type reuses the existing collector
+	if _, err := newCounter(prometheus.CounterOpts(opts), registry); err != nil {
+		t.Fatalf("re-registering same type: %v", err)
+	}
+}
+
+// TestConstLabels verifies that constant labels are attached to every
+// exported metric, including the standard Go collectors.
+func TestConstLabels(t *testing.T) {
+	m, err := New(GaugeFuncs{
+		GetUptimeSeconds: func() float64 { return 0 },
+		GetIdleSeconds:   func() float64 { return 0 },
+	}, prometheus.Labels{"region": "eu-west"})
+	if err != nil {
+		t.Fatalf("unexpected registration error: %v", err)
+	}
+	m.UpdateGeo(nil)
+
+	mfs, err := m.registry.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+
+	for _, mf := range mfs {
+		for _, metric := range mf.GetMetric() {
+			found := false
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "region" && label.GetValue() == "eu-west" {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("metric %q is missing the region label", mf.GetName())
+			}
+		}
+	}
+}
+
+func TestMetricsLastUpdated(t *testing.T) {
+	m, err := New(GaugeFuncs{
+		GetUptimeSeconds: func() float64 { return 0 },
+		GetIdleSeconds:   func() float64 { return 0 },
+	}, nil)
+	if err != nil {
+		t.Fatalf("unexpected registration error: %v", err)
+	}
+
+	lastUpdated := func() map[string]float64 {
+		mfs, err := m.registry.Gather()
+		if err != nil {
+			t.Fatalf("failed to gather metrics: %v", err)
+		}
+		updated := make(map[string]float64)
+		for _, mf := range mfs {
+			if mf.GetName() != "conduit_metrics_last_updated_seconds" {
+				continue
+			}
+			for _, metric := range mf.GetMetric() {
+				updated[metric.GetLabel()[0].GetValue()] = metric.GetGauge().GetValue()
+			}
+		}
+		return updated
+	}
+
+	before := float64(time.Now().Add(-time.Second).Unix())
+	updated := lastUpdated()
+	if updated["scrape"] < before {
+		t.Errorf("expected scrape to be now, got %v", updated["scrape"])
+	}
+	if _, ok := updated["activity"]; ok {
+		t.Errorf("activity reported before any activity update")
+	}
+
+	m.SetConnectedClients(3)
+	if got := lastUpdated()["activity"]; got < before {
+		t.Errorf("expected activity to be updated now, got %v", got)
+	}
+}
+
+// TestUnixSocketServer verifies that a unix: metrics address serves
+// /metrics on a group-accessible socket that is removed on shutdown.
+func TestUnixSocketServer(t *testing.T) {
+	m, err := New(GaugeFuncs{
+		GetUptimeSeconds: func() float64 { return 0 },
+		GetIdleSeconds:   func() float64 { return 0 },
+	}, nil)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "metrics.sock")
+	if err := m.StartServer("unix:" + path); err != nil {
+		t.Fatalf("StartServer: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat socket: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0660 {
+		t.Errorf("socket mode = %o, expected 660", perm)
+	}
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+	resp, err := client.Get("http://unix/metrics")
+	if err != nil {
+		t.Fatalf("scrape: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if !strings.Contains(string(body), "conduit_build_info") {
+		t.Errorf("scrape did not return conduit metrics")
+	}
+
+	if err := m.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("socket not removed on shutdown: %v", err)
+	}
+}
+
+// TestPrivacyGatherer verifies that privacy mode merges countries with few
+// clients and rounds client counts.
+func TestPrivacyGatherer(t *testing.T) {
+	m, err := New(GaugeFuncs{
+		GetUptimeSeconds: func() float64 { return 0 },
+		GetIdleSeconds:   func() float64 { return 0 },
+	}, nil)
+	if err != nil {
+		t.Fatalf("unexpected registration error: %v", err)
+	}
+	m.ConnectedClients.Set(12)
+	m.UpdateGeo([]geo.Result{
+		{Code: "IR", Count: 8, CountTotal: 40, BytesUp: 100},
+		{Code: "DE", Count: 1, CountTotal: 2, BytesUp: 10},
+		{Code: "FR", Count: 2, CountTotal: 3, BytesUp: 20},
+	})
+
+	mfs, err := privacyGatherer{m.registry}.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+
+	values := make(map[string]map[string]float64)
+	for _, mf := range mfs {
+		values[mf.GetName()] = make(map[string]float64)
+		for _, metric := range mf.GetMetric() {
+			values[mf.GetName()][labelValue(metric, "country_code")] = metricValue(metric)
+		}
+	}
+
+	if got := values["conduit_connected_clients"][""]; got != 10 {
+		t.Errorf("connected_clients = %v, expected 10", got)
+	}
+	expected := map[string]float64{"IR": 100, "OTHER": 30}
+	if got := values["conduit_geo_bytes_uploaded_total"]; !reflect.DeepEqual(got, expected) {
+		t.Errorf("geo_bytes_uploaded_total = %v, expected %v", got, expected)
+	}
+	expected = map[string]float64{"IR": 10, "OTHER": 5}
+	if got := values["conduit_geo_connected_clients"]; !reflect.DeepEqual(got, expected) {
+		t.Errorf("geo_connected_clients = %v, expected %v", got, expected)
+	}
+}
+
+// TestWriteText verifies the text exposition used by the control socket
+func TestWriteText(t *testing.T) {
+	m, err := New(GaugeFuncs{
+		GetUptimeSeconds: func() float64 { return 0 },
+		GetIdleSeconds:   func() float64 { return 0 },
+	}, nil)
+	if err != nil {
+		t.Fatalf("unexpected registration error: %v", err)
+	}
+	m.SetConfig(50, 0)
+
+	var buf strings.Builder
+	if err := m.WriteText(&buf); err != nil {
+		t.Fatalf("WriteText: %v", err)
+	}
+	if !strings.Contains(buf.String(), "\nconduit_max_clients 50\n") {
+		t.Errorf("expected conduit_max_clients 50 in output:\n%s", buf.String())
+	}
+	if strings.Contains(buf.String(), "\n\n") {
+		t.Errorf("output contains an empty line, which ends a control socket response")
+	}
+}
+
+func TestWriteInflux(t *testing.T) {
+	m, err := New(GaugeFuncs{
+		GetUptimeSeconds: func() float64 { return 0 },
+		GetIdleSeconds:   func() float64 { return 0 },
+	}, prometheus.Labels{"region": "eu west"})
+	if err != nil {
+		t.Fatalf("unexpected registration error: %v", err)
+	}
+	m.SetConfig(50, 0)
+	m.ObserveStatsWrite(2*time.Millisecond, nil)
+
+	var buf strings.Builder
+	now := time.Unix(1700000000, 0)
+	if err := m.WriteInflux(&buf, map[string]string{"instance": "inst-0"}, now); err != nil {
+		t.Fatalf("WriteInflux: %v", err)
+	}
+	out := buf.String()
+
+	expected := []string{
+		"conduit_max_clients,instance=inst-0,region=eu\\ west value=50 1700000000000000000\n",
+		"conduit_stats_write_duration_seconds,instance=inst-0,region=eu\\ west count=1,sum=0.002,0.001=0,0.004=1,",
+	}
+	for _, line := range expected {
+		if !strings.Contains(out, line) {
+			t.Errorf("expected %q in output:\n%s", line, out)
+		}
+	}
+	if strings.Contains(out, "+Inf") || strings.Contains(out, "NaN") {
+		t.Errorf("output contains a value line protocol can't express:\n%s", out)
+	}
+}
+
+// TestPushInfluxOnStop verifies that the last values are written when the
+// push stops, before the next tick is due
+func TestPushInfluxOnStop(t *testing.T) {
+	m, err := New(GaugeFuncs{
+		GetUptimeSeconds: func() float64 { return 0 },
+		GetIdleSeconds:   func() float64 { return 0 },
+	}, nil)
+	if err != nil {
+		t.Fatalf("unexpected registration error: %v", err)
+	}
+	m.AddForceDroppedClients(3)
+
+	path := filepath.Join(t.TempDir(), "metrics.lp")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	m.PushInflux(ctx, InfluxOptions{Addr: "file://" + filepath.ToSlash(path), Interval: time.Hour})
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("nothing written on stop: %v", err)
+	}
+	if !strings.Contains(string(data), "conduit_force_dropped_clients_total value=3 ") {
+		t.Errorf("expected the dropped clients count in:\n%s", data)
+	}
+
+	// A later push replaces the snapshot instead of appending to it
+	m.PushInflux(ctx, InfluxOptions{Addr: "file://" + filepath.ToSlash(path), Interval: time.Hour})
+	data, err = os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading after the second push: %v", err)
+	}
+	if n := strings.Count(string(data), "conduit_force_dropped_clients_total "); n != 1 {
+		t.Errorf("file holds %d snapshots, expected 1:\n%s", n, data)
+	}
+}
+
+func TestPushRemoteWrite(t *testing.T) {
+	m, err := New(GaugeFuncs{
+		GetUptimeSeconds: func() float64 { return 0 },
+		GetIdleSeconds:   func() float64 { return 0 },
+	}, nil)
+	if err != nil {
+		t.Fatalf("unexpected registration error: %v", err)
+	}
+	m.SetConfig(50, 0)
+
+	// The first request fails with a 503 and must be retried
+	bodies := make(chan []byte, 1)
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			http.Error(w, "overloaded", http.StatusServiceUnavailable)
+			return
+		}
+		if r.Header.Get("Content-Encoding") != "snappy" || r.Header.Get("X-Prometheus-Remote-Write-Version") != "0.1.0" {
+			t.Errorf("unexpected headers: %v", r.Header)
+		}
+		if user, password, ok := r.BasicAuth(); !ok || user != "writer" || password != "secret" {
+			t.Errorf("expected basic auth writer:secret, got %q:%q", user, password)
+		}
+		compressed, _ := io.ReadAll(r.Body)
+		body, err := snappy.Decode(nil, compressed)
+		if err != nil {
+			t.Errorf("body is not snappy compressed: %v", err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+		select {
+		case bodies <- body:
+		default:
+		}
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pushURL := strings.Replace(server.URL, "://", "://writer:secret@", 1) + "/api/v1/write"
+	go m.PushRemoteWrite(ctx, RemoteWriteOptions{
+		URL:      pushURL,
+		Interval: 1500 * time.Millisecond,
+		Labels:   map[string]string{"job": "conduit", "instance": "inst-0"},
+	})
+
+	select {
+	case body := <-bodies:
+		for _, want := range []string{"__name__", "conduit_max_clients", "job", "inst-0"} {
+			if !strings.Contains(string(body), want) {
+				t.Errorf("expected %q in the write request", want)
+			}
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("no remote write received after %d requests", requests.Load())
+	}
+}
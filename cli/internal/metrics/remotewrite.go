@@ -0,0 +1,278 @@
+/*
+ * Copyright (c) 2026, Psiphon Inc.
+ * All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Psiphon-Inc/conduit/cli/internal/logging"
+	"github.com/klauspost/compress/snappy"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// remoteWriteMaxSeries bounds the series in one remote write request, as
+// Prometheus' own max_samples_per_send does
+const remoteWriteMaxSeries = 500
+
+// remoteWriteMinBackoff is the first wait before retrying a failed request.
+// Each retry doubles it until the next push is due.
+const remoteWriteMinBackoff = time.Second
+
+// RemoteWriteOptions configures pushing metrics to a Prometheus remote write endpoint
+type RemoteWriteOptions struct {
+	URL         string            // Endpoint; user info in the URL is sent as basic auth
+	Interval    time.Duration     // How often metrics are pushed
+	BearerToken string            // Sent as an Authorization bearer token, if set
+	Labels      map[string]string // Added to every series, e.g. job and instance
+}
+
+// remoteSeries is one series of a remote write request, with its labels
+// (including __name__) sorted by name
+type remoteSeries struct {
+	labels [][2]string
+	value  float64
+}
+
+// remoteWriteSeries flattens the exported metrics into remote write series,
+// named as they are in the text exposition: histograms and summaries become
+// _bucket (or quantile), _sum and _count series
+func (m *Metrics) remoteWriteSeries(extra map[string]string) ([]remoteSeries, error) {
+	mfs, err := m.gatherer().Gather()
+	if err != nil {
+		return nil, err
+	}
+
+	var series []remoteSeries
+	for _, mf := range mfs {
+		name := mf.GetName()
+		for _, metric := range mf.GetMetric() {
+			add := func(name string, value float64, label ...string) {
+				series = append(series, remoteSeries{remoteLabels(extra, name, metric.GetLabel(), label...), value})
+			}
+			switch mf.GetType() {
+			case dto.MetricType_GAUGE:
+				add(name, metric.GetGauge().GetValue())
+			case dto.MetricType_COUNTER:
+				add(name, metric.GetCounter().GetValue())
+			case dto.MetricType_UNTYPED:
+				add(name, metric.GetUntyped().GetValue())
+			case dto.MetricType_HISTOGRAM:
+				h := metric.GetHistogram()
+				sawInf := false
+				for _, b := range h.GetBucket() {
+					sawInf = sawInf || math.IsInf(b.GetUpperBound(), 1)
+					add(name+"_bucket", float64(b.GetCumulativeCount()), "le", formatRemoteFloat(b.GetUpperBound()))
+				}
+				if !sawInf {
+					add(name+"_bucket", float64(h.GetSampleCount()), "le", "+Inf")
+				}
+				add(name+"_sum", h.GetSampleSum())
+				add(name+"_count", float64(h.GetSampleCount()))
+			case dto.MetricType_SUMMARY:
+				s := metric.GetSummary()
+				for _, q := range s.GetQuantile() {
+					add(name, q.GetValue(), "quantile", formatRemoteFloat(q.GetQuantile()))
+				}
+				add(name+"_sum", s.GetSampleSum())
+				add(name+"_count", float64(s.GetSampleCount()))
+			}
+		}
+	}
+	return series, nil
+}
+
+// remoteLabels merges the extra labels, the metric's labels and any extra
+// name/value pair (later ones win) and sorts them by name
+func remoteLabels(extra map[string]string, name string, labels []*dto.LabelPair, pair ...string) [][2]string {
+	merged := make(map[string]string, len(extra)+len(labels)+2)
+	for key, value := range extra {
+		merged[key] = value
+	}
+	for _, label := range labels {
+		merged[label.GetName()] = label.GetValue()
+	}
+	if len(pair) == 2 {
+		merged[pair[0]] = pair[1]
+	}
+	merged["__name__"] = name
+
+	sorted := make([][2]string, 0, len(merged))
+	for key, value := range merged {
+		// An empty label is the same as no label
+		if value != "" {
+			sorted = append(sorted, [2]string{key, value})
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i][0] < sorted[j][0] })
+	return sorted
+}
+
+func formatRemoteFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// encodeWriteRequest encodes series as a prometheus.WriteRequest protobuf
+// with every sample at timestamp now
+func encodeWriteRequest(series []remoteSeries, now time.Time) []byte {
+	var request []byte
+	for _, s := range series {
+		var ts []byte
+		for _, label := range s.labels {
+			var l []byte
+			l = protowire.AppendTag(l, 1, protowire.BytesType)
+			l = protowire.AppendString(l, label[0])
+			l = protowire.AppendTag(l, 2, protowire.BytesType)
+			l = protowire.AppendString(l, label[1])
+			ts = protowire.AppendTag(ts, 1, protowire.BytesType)
+			ts = protowire.AppendBytes(ts, l)
+		}
+		var sample []byte
+		sample = protowire.AppendTag(sample, 1, protowire.Fixed64Type)
+		sample = protowire.AppendFixed64(sample, math.Float64bits(s.value))
+		sample = protowire.AppendTag(sample, 2, protowire.VarintType)
+		sample = protowire.AppendVarint(sample, uint64(now.UnixMilli()))
+		ts = protowire.AppendTag(ts, 2, protowire.BytesType)
+		ts = protowire.AppendBytes(ts, sample)
+
+		request = protowire.AppendTag(request, 1, protowire.BytesType)
+		request = protowire.AppendBytes(request, ts)
+	}
+	return request
+}
+
+// errPermanent marks a remote write failure that retrying won't fix
+var errPermanent = errors.New("not retried")
+
+// PushRemoteWrite sends the exported metrics to opts.URL every opts.Interval
+// until ctx is done, in batches of at most remoteWriteMaxSeries series.
+// A batch that fails with a network error, a 5xx or a 429 is retried with
+// backoff until the next push is due, then dropped: the next push carries
+// current values of the same series. Once ctx is done the metrics are sent
+// one last time, so that the final values are not lost.
+func (m *Metrics) PushRemoteWrite(ctx context.Context, opts RemoteWriteOptions) {
+	u, err := url.Parse(opts.URL)
+	if err != nil {
+		logging.Printf("[ERROR] Invalid remote write URL %s: %v\n", opts.URL, err)
+		return
+	}
+	user := u.User
+	u.User = nil
+	client := &http.Client{Timeout: min(opts.Interval, influxHTTPTimeout)}
+
+	push := func(ctx context.Context, now time.Time) {
+		series, err := m.remoteWriteSeries(opts.Labels)
+		if err != nil {
+			logging.Printf("[WARN] Failed to gather metrics for remote write: %v\n", err)
+			return
+		}
+		for start := 0; start < len(series); start += remoteWriteMaxSeries {
+			batch := series[start:min(start+remoteWriteMaxSeries, len(series))]
+			body := snappy.Encode(nil, encodeWriteRequest(batch, now))
+			if err := sendRemoteWrite(ctx, client, u, user, opts.BearerToken, body); err != nil {
+				logging.Printf("[WARN] Failed to push metrics to %s: %v\n", u.Redacted(), err)
+				return
+			}
+		}
+	}
+
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			now := time.Now()
+			finalCtx, cancel := context.WithDeadline(context.Background(), now.Add(client.Timeout))
+			push(finalCtx, now)
+			cancel()
+			return
+		case now := <-ticker.C:
+			pushCtx, cancelPush := context.WithDeadline(ctx, now.Add(opts.Interval))
+			push(pushCtx, now)
+			cancelPush()
+		}
+	}
+}
+
+// sendRemoteWrite posts one compressed WriteRequest, retrying recoverable
+// failures until ctx is done
+func sendRemoteWrite(ctx context.Context, client *http.Client, u *url.URL, user *url.Userinfo, token string, body []byte) error {
+	backoff := remoteWriteMinBackoff
+	for {
+		err := postRemoteWrite(ctx, client, u, user, token, body)
+		if err == nil || errors.Is(err, errPermanent) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func postRemoteWrite(ctx context.Context, client *http.Client, u *url.URL, user *url.Userinfo, token string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("%w: %v", errPermanent, err)
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	req.Header.Set("User-Agent", "conduit")
+	if user != nil {
+		password, _ := user.Password()
+		req.SetBasicAuth(user.Username(), password)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode <= 299:
+		return nil
+	case resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests:
+		return fmt.Errorf("server returned %s: %s", resp.Status, bytes.TrimSpace(message))
+	default:
+		return fmt.Errorf("server returned %s: %s (%w)", resp.Status, bytes.TrimSpace(message), errPermanent)
+	}
+}
@@ -0,0 +1,140 @@
+/*
+ * Copyright (c) 2026, Psiphon Inc.
+ * All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package notice fetches signed notices that Psiphon publishes to operators,
+// such as requests to upgrade
+package notice
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// fetchTimeout bounds a single fetch, including reading the body
+const fetchTimeout = 30 * time.Second
+
+// maxNoticeSize caps the response size; notices are a few lines of text
+const maxNoticeSize = 64 << 10
+
+// Notice is a message for operators
+type Notice struct {
+	Message string    `json:"message"`
+	URL     string    `json:"url,omitempty"`     // Where to read more
+	Expires time.Time `json:"expires,omitempty"` // Zero = never
+}
+
+// document is the published form of a notice. The signature covers the
+// decoded notice bytes, so they are verified before being parsed.
+type document struct {
+	Notice    string `json:"notice"`    // Base64-encoded Notice JSON
+	Signature string `json:"signature"` // Base64-encoded Ed25519 signature
+}
+
+// ParseKey decodes a base64-encoded Ed25519 public key
+func ParseKey(s string) (ed25519.PublicKey, error) {
+	key, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		key, err = base64.RawStdEncoding.DecodeString(s)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid notice key: %w", err)
+	}
+	if len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid notice key: expected %d bytes, got %d", ed25519.PublicKeySize, len(key))
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// Fetch retrieves the notice published at url. It returns nil if there is
+// no current notice: the document is missing (404) or has expired.
+func Fetch(ctx context.Context, url string, key ed25519.PublicKey) (*Notice, error) {
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch notice: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch notice: %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxNoticeSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read notice: %w", err)
+	}
+	if len(body) > maxNoticeSize {
+		return nil, fmt.Errorf("notice is larger than %d bytes", maxNoticeSize)
+	}
+
+	n, err := Verify(body, key)
+	if err != nil {
+		return nil, err
+	}
+	if !n.Expires.IsZero() && time.Now().After(n.Expires) {
+		return nil, nil
+	}
+	return n, nil
+}
+
+// Verify checks the signature of a published notice document and returns
+// the notice it holds
+func Verify(data []byte, key ed25519.PublicKey) (*Notice, error) {
+	var doc document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid notice document: %w", err)
+	}
+	payload, err := base64.StdEncoding.DecodeString(doc.Notice)
+	if err != nil {
+		return nil, fmt.Errorf("invalid notice document: %w", err)
+	}
+	signature, err := base64.StdEncoding.DecodeString(doc.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("invalid notice signature: %w", err)
+	}
+	if !ed25519.Verify(key, payload, signature) {
+		return nil, errors.New("notice signature does not match the notice key")
+	}
+
+	var n Notice
+	if err := json.Unmarshal(payload, &n); err != nil {
+		return nil, fmt.Errorf("invalid notice: %w", err)
+	}
+	if n.Message == "" {
+		return nil, errors.New("invalid notice: message is empty")
+	}
+	return &n, nil
+}
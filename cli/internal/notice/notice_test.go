@@ -0,0 +1,144 @@
+/*
+ * Copyright (c) 2026, Psiphon Inc.
+ * All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package notice
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// sign returns a published document holding n, signed with key
+func sign(t *testing.T, n Notice, key ed25519.PrivateKey) []byte {
+	t.Helper()
+	payload, err := json.Marshal(n)
+	if err != nil {
+		t.Fatalf("marshal notice: %v", err)
+	}
+	data, err := json.Marshal(document{
+		Notice:    base64.StdEncoding.EncodeToString(payload),
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(key, payload)),
+	})
+	if err != nil {
+		t.Fatalf("marshal document: %v", err)
+	}
+	return data
+}
+
+func TestVerify(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	_, otherPrivate, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+
+	n, err := Verify(sign(t, Notice{Message: "Please upgrade"}, private), public)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if n.Message != "Please upgrade" {
+		t.Errorf("Message = %q, expected %q", n.Message, "Please upgrade")
+	}
+
+	if _, err := Verify(sign(t, Notice{Message: "Please upgrade"}, otherPrivate), public); err == nil {
+		t.Error("expected an error for a notice signed with another key")
+	}
+
+	var doc document
+	_ = json.Unmarshal(sign(t, Notice{Message: "Please upgrade"}, private), &doc)
+	doc.Notice = base64.StdEncoding.EncodeToString([]byte(`{"message":"Please downgrade"}`))
+	tampered, _ := json.Marshal(doc)
+	if _, err := Verify(tampered, public); err == nil {
+		t.Error("expected an error for a tampered notice")
+	}
+
+	if _, err := Verify(sign(t, Notice{}, private), public); err == nil {
+		t.Error("expected an error for an empty message")
+	}
+}
+
+func TestFetch(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/current":
+			_, _ = w.Write(sign(t, Notice{Message: "Please upgrade", Expires: time.Now().Add(time.Hour)}, private))
+		case "/expired":
+			_, _ = w.Write(sign(t, Notice{Message: "Please upgrade", Expires: time.Now().Add(-time.Hour)}, private))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	n, err := Fetch(context.Background(), server.URL+"/current", public)
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if n == nil || n.Message != "Please upgrade" {
+		t.Fatalf("unexpected notice %+v", n)
+	}
+
+	for _, path := range []string{"/expired", "/missing"} {
+		n, err := Fetch(context.Background(), server.URL+path, public)
+		if err != nil {
+			t.Fatalf("Fetch %s: %v", path, err)
+		}
+		if n != nil {
+			t.Errorf("Fetch %s = %+v, expected no notice", path, n)
+		}
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	public, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+
+	for _, s := range []string{
+		base64.StdEncoding.EncodeToString(public),
+		base64.RawStdEncoding.EncodeToString(public),
+	} {
+		key, err := ParseKey(s)
+		if err != nil {
+			t.Fatalf("ParseKey(%q): %v", s, err)
+		}
+		if !key.Equal(public) {
+			t.Errorf("ParseKey(%q) returned a different key", s)
+		}
+	}
+
+	if _, err := ParseKey(base64.StdEncoding.EncodeToString(public[:16])); err == nil {
+		t.Error("expected an error for a short key")
+	}
+}
@@ -0,0 +1,186 @@
+/*
+ * Copyright (c) 2026, Psiphon Inc.
+ * All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package probe checks that the broker endpoints in a Psiphon config can be
+// reached from this host, without talking to the broker itself
+package probe
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/Psiphon-Labs/psiphon-tunnel-core/psiphon/common/regen"
+)
+
+// brokerPort is the port broker endpoints are dialed on
+const brokerPort = "443"
+
+// ErrNoBrokerSpecs is returned for a config without broker specs, which the
+// proxy then receives in tactics at runtime
+var ErrNoBrokerSpecs = errors.New("config has no broker specs (they are fetched as tactics at runtime)")
+
+// Endpoint is one address a broker can be reached at
+type Endpoint struct {
+	ProviderID       string
+	Address          string // Host, or host:port
+	SNI              string // Empty = no SNI
+	VerifyServerName string // Name the certificate must be valid for (empty = not checked)
+}
+
+// Result is the outcome of probing an endpoint
+type Result struct {
+	Endpoint
+	TCPLatency time.Duration // Time to connect
+	TLSLatency time.Duration // Time for the TLS handshake after connecting
+	Err        error
+}
+
+// brokerSpecs holds the parts of a Psiphon config's broker specs that
+// describe how brokers are reached
+type brokerSpecs []struct {
+	BrokerFrontingSpecs []struct {
+		FrontingProviderID string
+		Addresses          []string
+		DisableSNI         bool
+		VerifyServerName   string
+	}
+}
+
+// Endpoints returns the broker endpoints in a Psiphon config. The proxy
+// broker specs are used if present. Addresses are regular expressions, as
+// tunnel-core reads them; each is expanded to one sample address.
+func Endpoints(configData []byte) ([]Endpoint, error) {
+	var config struct {
+		InproxyBrokerSpecs      brokerSpecs
+		InproxyProxyBrokerSpecs brokerSpecs
+	}
+	if err := json.Unmarshal(configData, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse psiphon config: %w", err)
+	}
+	specs := config.InproxyProxyBrokerSpecs
+	if len(specs) == 0 {
+		specs = config.InproxyBrokerSpecs
+	}
+
+	var endpoints []Endpoint
+	seen := make(map[string]bool)
+	for _, spec := range specs {
+		for _, fronting := range spec.BrokerFrontingSpecs {
+			for _, pattern := range fronting.Addresses {
+				address, err := regen.GenerateString(pattern)
+				if err != nil {
+					return nil, fmt.Errorf("invalid broker address %q: %w", pattern, err)
+				}
+				if seen[fronting.FrontingProviderID+" "+address] {
+					continue
+				}
+				seen[fronting.FrontingProviderID+" "+address] = true
+
+				endpoint := Endpoint{
+					ProviderID:       fronting.FrontingProviderID,
+					Address:          address,
+					VerifyServerName: fronting.VerifyServerName,
+				}
+				if !fronting.DisableSNI && net.ParseIP(address) == nil {
+					endpoint.SNI = address
+				}
+				endpoints = append(endpoints, endpoint)
+			}
+		}
+	}
+	if len(endpoints) == 0 {
+		return nil, ErrNoBrokerSpecs
+	}
+	return endpoints, nil
+}
+
+// ProbeAll probes each endpoint concurrently, returning results in order
+func ProbeAll(ctx context.Context, endpoints []Endpoint, timeout time.Duration) []Result {
+	results := make([]Result, len(endpoints))
+	var wg sync.WaitGroup
+	for i, endpoint := range endpoints {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = Probe(ctx, endpoint, timeout)
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// Probe connects to an endpoint and completes a TLS handshake with it, then
+// hangs up. Nothing is sent to the broker.
+func Probe(ctx context.Context, endpoint Endpoint, timeout time.Duration) Result {
+	result := Result{Endpoint: endpoint}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	address := endpoint.Address
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, brokerPort)
+	}
+
+	start := time.Now()
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	defer conn.Close()
+	result.TCPLatency = time.Since(start)
+
+	// The certificate is checked below against VerifyServerName, which for
+	// fronted brokers differs from the SNI
+	tlsConn := tls.Client(conn, &tls.Config{
+		ServerName:         endpoint.SNI,
+		InsecureSkipVerify: true,
+	})
+	start = time.Now()
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		result.Err = fmt.Errorf("TLS handshake failed: %w", err)
+		return result
+	}
+	result.TLSLatency = time.Since(start)
+
+	if endpoint.VerifyServerName != "" {
+		certs := tlsConn.ConnectionState().PeerCertificates
+		intermediates := x509.NewCertPool()
+		for _, cert := range certs[1:] {
+			intermediates.AddCert(cert)
+		}
+		if _, err := certs[0].Verify(x509.VerifyOptions{
+			DNSName:       endpoint.VerifyServerName,
+			Intermediates: intermediates,
+		}); err != nil {
+			result.Err = fmt.Errorf("certificate not valid for %s: %w", endpoint.VerifyServerName, err)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,99 @@
+/*
+ * Copyright (c) 2026, Psiphon Inc.
+ * All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package probe
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEndpoints(t *testing.T) {
+	// Addresses are regular expressions, as in tunnel-core
+	data := []byte(`{
+		"InproxyBrokerSpecs": [{"BrokerFrontingSpecs": [{"FrontingProviderID": "ignored", "Addresses": ["other.example.com"]}]}],
+		"InproxyProxyBrokerSpecs": [{
+			"BrokerPublicKey": "secret",
+			"BrokerFrontingSpecs": [
+				{"FrontingProviderID": "A", "Addresses": ["front\\.example\\.com", "192\\.0\\.2\\.1"], "VerifyServerName": "broker.example.com"},
+				{"FrontingProviderID": "B", "Addresses": ["cdn[0-9]\\.example\\.net"], "DisableSNI": true}
+			]
+		}]
+	}`)
+
+	endpoints, err := Endpoints(data)
+	if err != nil {
+		t.Fatalf("Endpoints: %v", err)
+	}
+	if len(endpoints) != 3 {
+		t.Fatalf("endpoints = %+v, expected 3 from the proxy broker specs", endpoints)
+	}
+	expected := Endpoint{ProviderID: "A", Address: "front.example.com", SNI: "front.example.com", VerifyServerName: "broker.example.com"}
+	if !reflect.DeepEqual(endpoints[0], expected) {
+		t.Errorf("endpoints[0] = %+v, expected %+v", endpoints[0], expected)
+	}
+	if endpoints[1].SNI != "" {
+		t.Errorf("IP address endpoint has SNI %q, expected none", endpoints[1].SNI)
+	}
+	if !strings.HasPrefix(endpoints[2].Address, "cdn") || endpoints[2].SNI != "" {
+		t.Errorf("endpoints[2] = %+v, expected a sampled cdn address without SNI", endpoints[2])
+	}
+
+	if _, err := Endpoints([]byte(`{"SponsorId": "ABC"}`)); !errors.Is(err, ErrNoBrokerSpecs) {
+		t.Errorf("Endpoints without specs: err = %v, expected ErrNoBrokerSpecs", err)
+	}
+}
+
+func TestProbe(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	address := strings.TrimPrefix(server.URL, "https://")
+
+	result := Probe(context.Background(), Endpoint{Address: address, SNI: "example.com"}, 5*time.Second)
+	if result.Err != nil {
+		t.Fatalf("Probe: %v", result.Err)
+	}
+	if result.TCPLatency <= 0 || result.TLSLatency <= 0 {
+		t.Errorf("latencies = %v, %v, expected both to be measured", result.TCPLatency, result.TLSLatency)
+	}
+
+	// The test server's certificate is self-signed
+	result = Probe(context.Background(), Endpoint{Address: address, VerifyServerName: "example.com"}, 5*time.Second)
+	if result.Err == nil {
+		t.Errorf("expected a certificate error")
+	}
+
+	// Nothing listening
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	closed := listener.Addr().String()
+	_ = listener.Close()
+	if result := Probe(context.Background(), Endpoint{Address: closed}, 5*time.Second); result.Err == nil {
+		t.Errorf("expected an error probing %s", closed)
+	}
+}
@@ -0,0 +1,167 @@
+/*
+ * Copyright (c) 2026, Psiphon Inc.
+ * All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package remoteconfig fetches the Psiphon config from a key-value store, so
+// that it never has to be written to the filesystem
+package remoteconfig
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Environment variables holding the store tokens, as used by the Vault and
+// Consul CLIs
+const (
+	VaultTokenEnv  = "VAULT_TOKEN"
+	ConsulTokenEnv = "CONSUL_HTTP_TOKEN"
+)
+
+// fetchTimeout bounds a single fetch, including reading the body
+const fetchTimeout = 30 * time.Second
+
+// maxConfigSize caps the response size; Psiphon configs are a few KB
+const maxConfigSize = 1 << 20
+
+// Fetch retrieves the config named by source. Supported sources:
+//
+//	vault://host[:port]/<mount>/data/<path>[#field]  Vault KV v2 secret
+//	consul://host[:port]/<key>                      Consul KV key
+//
+// Use vault+http:// or consul+http:// to talk to a local agent without TLS.
+// For Vault the secret's data is the config, unless #field names a single
+// field holding it. Tokens are read from VAULT_TOKEN and CONSUL_HTTP_TOKEN.
+func Fetch(ctx context.Context, source string) ([]byte, error) {
+	u, err := url.Parse(source)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config source %q: %w", source, err)
+	}
+
+	kind, scheme, err := splitScheme(u.Scheme)
+	if err != nil {
+		return nil, err
+	}
+	if u.Host == "" || strings.Trim(u.Path, "/") == "" {
+		return nil, fmt.Errorf("invalid config source %q: expected %s://host/path", source, kind)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+
+	switch kind {
+	case "vault":
+		endpoint := fmt.Sprintf("%s://%s/v1/%s", scheme, u.Host, strings.TrimPrefix(u.Path, "/"))
+		body, err := get(ctx, endpoint, "X-Vault-Token", os.Getenv(VaultTokenEnv))
+		if err != nil {
+			return nil, err
+		}
+		return vaultSecret(body, u.Fragment)
+
+	default:
+		endpoint := fmt.Sprintf("%s://%s/v1/kv/%s?raw", scheme, u.Host, strings.TrimPrefix(u.Path, "/"))
+		return get(ctx, endpoint, "X-Consul-Token", os.Getenv(ConsulTokenEnv))
+	}
+}
+
+// splitScheme returns the store kind and the HTTP scheme to reach it with
+func splitScheme(s string) (string, string, error) {
+	kind, transport, found := strings.Cut(s, "+")
+	if kind != "vault" && kind != "consul" {
+		return "", "", fmt.Errorf("unsupported config source scheme %q (use vault:// or consul://)", s)
+	}
+	if !found {
+		return kind, "https", nil
+	}
+	if transport != "http" && transport != "https" {
+		return "", "", fmt.Errorf("unsupported config source scheme %q", s)
+	}
+	return kind, transport, nil
+}
+
+// get fetches endpoint, sending token in header if set
+func get(ctx context.Context, endpoint, header, token string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set(header, token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch config: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxConfigSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		// Error bodies can't contain the secret, but keep them short
+		detail := strings.TrimSpace(string(body))
+		if len(detail) > 200 {
+			detail = detail[:200]
+		}
+		return nil, fmt.Errorf("failed to fetch config: %s: %s", resp.Status, detail)
+	}
+	if len(body) > maxConfigSize {
+		return nil, fmt.Errorf("config is larger than %d bytes", maxConfigSize)
+	}
+
+	return body, nil
+}
+
+// vaultSecret extracts the config from a Vault KV v2 read response
+func vaultSecret(body []byte, field string) ([]byte, error) {
+	var response struct {
+		Data struct {
+			Data map[string]json.RawMessage `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse vault response: %w", err)
+	}
+	if response.Data.Data == nil {
+		return nil, fmt.Errorf("vault response has no secret data (is this a KV v2 data path?)")
+	}
+
+	if field == "" {
+		return json.Marshal(response.Data.Data)
+	}
+
+	raw, ok := response.Data.Data[field]
+	if !ok {
+		return nil, fmt.Errorf("vault secret has no field %q", field)
+	}
+	// The field may hold the config as a JSON string or as a nested object
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return []byte(text), nil
+	}
+	return raw, nil
+}
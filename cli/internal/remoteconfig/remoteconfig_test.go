@@ -0,0 +1,118 @@
+/*
+ * Copyright (c) 2026, Psiphon Inc.
+ * All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package remoteconfig
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFetchVault(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "test-token" {
+			http.Error(w, `{"errors":["permission denied"]}`, http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/conduit":
+			_, _ = w.Write([]byte(`{"data":{"data":{"PropagationChannelId":"ABC","SponsorId":"DEF"},"metadata":{}}}`))
+		case "/v1/secret/data/wrapped":
+			_, _ = w.Write([]byte(`{"data":{"data":{"config":"{\"SponsorId\":\"DEF\"}"}}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	t.Setenv(VaultTokenEnv, "test-token")
+
+	data, err := Fetch(context.Background(), "vault+http://"+host+"/secret/data/conduit")
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	var fields map[string]string
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatalf("unmarshal %q: %v", data, err)
+	}
+	if fields["PropagationChannelId"] != "ABC" || fields["SponsorId"] != "DEF" {
+		t.Fatalf("unexpected config %q", data)
+	}
+
+	data, err = Fetch(context.Background(), "vault+http://"+host+"/secret/data/wrapped#config")
+	if err != nil {
+		t.Fatalf("Fetch with field: %v", err)
+	}
+	if string(data) != `{"SponsorId":"DEF"}` {
+		t.Fatalf("field config = %q", data)
+	}
+
+	if _, err := Fetch(context.Background(), "vault+http://"+host+"/secret/data/wrapped#missing"); err == nil {
+		t.Fatalf("expected error for a missing field")
+	}
+
+	t.Setenv(VaultTokenEnv, "wrong")
+	if _, err := Fetch(context.Background(), "vault+http://"+host+"/secret/data/conduit"); err == nil {
+		t.Fatalf("expected error with a rejected token")
+	}
+}
+
+func TestFetchConsul(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/kv/conduit/psiphon" || !r.URL.Query().Has("raw") {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("X-Consul-Token") != "consul-token" {
+			http.Error(w, "ACL not found", http.StatusForbidden)
+			return
+		}
+		_, _ = w.Write([]byte(`{"SponsorId":"DEF"}`))
+	}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	t.Setenv(ConsulTokenEnv, "consul-token")
+
+	data, err := Fetch(context.Background(), "consul+http://"+host+"/conduit/psiphon")
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if string(data) != `{"SponsorId":"DEF"}` {
+		t.Fatalf("config = %q", data)
+	}
+}
+
+func TestFetchInvalidSource(t *testing.T) {
+	for _, source := range []string{
+		"file:///etc/conduit.json",
+		"vault+ftp://host/secret/data/x",
+		"vault://",
+		"consul://host",
+	} {
+		if _, err := Fetch(context.Background(), source); err == nil {
+			t.Errorf("Fetch(%q): expected error", source)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+/*
+ * Copyright (c) 2026, Psiphon Inc.
+ * All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package systemd implements the parts of the systemd service protocols used by Conduit
+package systemd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// listenFdsStart is the first file descriptor passed by systemd (SD_LISTEN_FDS_START)
+const listenFdsStart = 3
+
+// Socket names matched against FileDescriptorName= in the .socket unit
+const (
+	MetricsSocketName = "metrics"
+	ControlSocketName = "control"
+)
+
+var (
+	activationOnce  sync.Once
+	activationFiles map[string]*os.File
+	activationErr   error
+)
+
+// parseListenEnv validates the LISTEN_PID/LISTEN_FDS/LISTEN_FDNAMES values
+// for process pid and returns the name of each passed descriptor, in order.
+// It returns nil when the process was not socket-activated.
+func parseListenEnv(pid int, listenPID, listenFDs, listenFDNames string) ([]string, error) {
+	if listenPID == "" || listenFDs == "" {
+		return nil, nil
+	}
+
+	p, err := strconv.Atoi(listenPID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid LISTEN_PID %q", listenPID)
+	}
+	if p != pid {
+		// The variables were meant for another process (e.g. our parent)
+		return nil, nil
+	}
+
+	n, err := strconv.Atoi(listenFDs)
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("invalid LISTEN_FDS %q", listenFDs)
+	}
+	if n == 0 {
+		return nil, nil
+	}
+
+	names := make([]string, n)
+	var given []string
+	if listenFDNames != "" {
+		given = strings.Split(listenFDNames, ":")
+		if len(given) != n {
+			return nil, fmt.Errorf("LISTEN_FDNAMES has %d names for %d descriptors", len(given), n)
+		}
+	}
+	for i := range names {
+		names[i] = "unknown"
+		if given != nil && given[i] != "" {
+			names[i] = given[i]
+		}
+	}
+
+	return names, nil
+}
+
+// loadActivationFiles reads and clears the socket activation environment,
+// so that child processes don't try to adopt the same descriptors
+func loadActivationFiles() (map[string]*os.File, error) {
+	names, err := parseListenEnv(
+		os.Getpid(),
+		os.Getenv("LISTEN_PID"),
+		os.Getenv("LISTEN_FDS"),
+		os.Getenv("LISTEN_FDNAMES"),
+	)
+	_ = os.Unsetenv("LISTEN_PID")
+	_ = os.Unsetenv("LISTEN_FDS")
+	_ = os.Unsetenv("LISTEN_FDNAMES")
+	if err != nil || names == nil {
+		return nil, err
+	}
+
+	indexes, err := selectSockets(names)
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]*os.File, len(indexes))
+	for name, i := range indexes {
+		files[name] = os.NewFile(uintptr(listenFdsStart+i), name)
+	}
+
+	return files, nil
+}
+
+// selectSockets returns the index of each passed descriptor that Conduit
+// uses, by name. Descriptors with other names are ignored, but each name
+// Conduit uses may only be passed once.
+func selectSockets(names []string) (map[string]int, error) {
+	indexes := make(map[string]int)
+	for i, name := range names {
+		if name != MetricsSocketName && name != ControlSocketName {
+			continue
+		}
+		if _, exists := indexes[name]; exists {
+			return nil, fmt.Errorf("socket activation passed more than one descriptor named %q; give each socket its own .socket unit with a distinct FileDescriptorName=", name)
+		}
+		indexes[name] = i
+	}
+	return indexes, nil
+}
+
+// Listener returns a listener for the socket named name that was passed in
+// by systemd socket activation, or nil if there is none. Each call returns a
+// new listener on a duplicate of the descriptor, so closing it (e.g. on an
+// idle restart) leaves the activated socket open for the next caller.
+func Listener(name string) (net.Listener, error) {
+	activationOnce.Do(func() {
+		activationFiles, activationErr = loadActivationFiles()
+	})
+	if activationErr != nil {
+		return nil, activationErr
+	}
+
+	f, ok := activationFiles[name]
+	if !ok {
+		return nil, nil
+	}
+
+	listener, err := net.FileListener(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to adopt activated socket %q: %w", name, err)
+	}
+
+	return listener, nil
+}
@@ -0,0 +1,98 @@
+/*
+ * Copyright (c) 2026, Psiphon Inc.
+ * All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package systemd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseListenEnv(t *testing.T) {
+	const pid = 4242
+
+	tests := []struct {
+		name      string
+		listenPID string
+		listenFDs string
+		fdNames   string
+		expected  []string
+		wantErr   bool
+	}{
+		{name: "not_activated"},
+		{name: "other_process", listenPID: "1", listenFDs: "2"},
+		{name: "zero_fds", listenPID: "4242", listenFDs: "0"},
+		{name: "unnamed", listenPID: "4242", listenFDs: "2", expected: []string{"unknown", "unknown"}},
+		{name: "named", listenPID: "4242", listenFDs: "2", fdNames: "metrics:control", expected: []string{"metrics", "control"}},
+		{name: "partly_named", listenPID: "4242", listenFDs: "2", fdNames: ":control", expected: []string{"unknown", "control"}},
+		{name: "bad_pid", listenPID: "abc", listenFDs: "1", wantErr: true},
+		{name: "bad_fds", listenPID: "4242", listenFDs: "-1", wantErr: true},
+		{name: "name_count_mismatch", listenPID: "4242", listenFDs: "2", fdNames: "metrics", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			names, err := parseListenEnv(pid, test.listenPID, test.listenFDs, test.fdNames)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %v", names)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseListenEnv: %v", err)
+			}
+			if !reflect.DeepEqual(names, test.expected) {
+				t.Fatalf("names = %v, expected %v", names, test.expected)
+			}
+		})
+	}
+}
+
+func TestSelectSockets(t *testing.T) {
+	tests := []struct {
+		name     string
+		names    []string
+		expected map[string]int
+		wantErr  bool
+	}{
+		{name: "both", names: []string{"metrics", "control"}, expected: map[string]int{"metrics": 0, "control": 1}},
+		{name: "other_names_ignored", names: []string{"unknown", "unknown", "control", "dashboard"}, expected: map[string]int{"control": 2}},
+		{name: "none_used", names: []string{"unknown"}, expected: map[string]int{}},
+		{name: "duplicate", names: []string{"metrics", "metrics"}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			indexes, err := selectSockets(test.names)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %v", indexes)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("selectSockets: %v", err)
+			}
+			if !reflect.DeepEqual(indexes, test.expected) {
+				t.Fatalf("indexes = %v, expected %v", indexes, test.expected)
+			}
+		})
+	}
+}
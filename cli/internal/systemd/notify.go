@@ -0,0 +1,101 @@
+/*
+ * Copyright (c) 2026, Psiphon Inc.
+ * All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package systemd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Notify sends state (e.g. "READY=1") to the service manager over the socket
+// in NOTIFY_SOCKET, as sd_notify does. It returns false without an error
+// when the process wasn't started by systemd with a notify socket.
+func Notify(state string) (bool, error) {
+	path := os.Getenv("NOTIFY_SOCKET")
+	if path == "" {
+		return false, nil
+	}
+	// A leading @ names a socket in the abstract namespace
+	if path[0] == '@' {
+		path = "\x00" + path[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		return false, fmt.Errorf("failed to connect to the systemd notify socket: %w", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return false, fmt.Errorf("failed to notify systemd: %w", err)
+	}
+	return true, nil
+}
+
+// parseWatchdogEnv returns the watchdog timeout from WATCHDOG_PID and
+// WATCHDOG_USEC for process pid, or 0 when no watchdog is configured for it
+func parseWatchdogEnv(pid int, watchdogPID, watchdogUsec string) (time.Duration, error) {
+	if watchdogUsec == "" {
+		return 0, nil
+	}
+	if watchdogPID != "" {
+		p, err := strconv.Atoi(watchdogPID)
+		if err != nil {
+			return 0, fmt.Errorf("invalid WATCHDOG_PID %q", watchdogPID)
+		}
+		if p != pid {
+			return 0, nil
+		}
+	}
+
+	usec, err := strconv.ParseInt(watchdogUsec, 10, 64)
+	if err != nil || usec <= 0 {
+		return 0, fmt.Errorf("invalid WATCHDOG_USEC %q", watchdogUsec)
+	}
+	return time.Duration(usec) * time.Microsecond, nil
+}
+
+// Watchdog sends WATCHDOG=1 at half the watchdog timeout configured with
+// WatchdogSec= until ctx is cancelled. It returns at once when there is no
+// watchdog.
+func Watchdog(ctx context.Context) error {
+	timeout, err := parseWatchdogEnv(os.Getpid(), os.Getenv("WATCHDOG_PID"), os.Getenv("WATCHDOG_USEC"))
+	if err != nil || timeout == 0 {
+		return err
+	}
+
+	ticker := time.NewTicker(timeout / 2)
+	defer ticker.Stop()
+
+	for {
+		if _, err := Notify("WATCHDOG=1"); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,97 @@
+/*
+ * Copyright (c) 2026, Psiphon Inc.
+ * All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package systemd
+
+import (
+	"net"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestParseWatchdogEnv(t *testing.T) {
+	const pid = 4242
+
+	tests := []struct {
+		name        string
+		watchdogPID string
+		usec        string
+		expected    time.Duration
+		wantErr     bool
+	}{
+		{name: "no_watchdog"},
+		{name: "any_process", usec: "30000000", expected: 30 * time.Second},
+		{name: "this_process", watchdogPID: "4242", usec: "500000", expected: 500 * time.Millisecond},
+		{name: "other_process", watchdogPID: "1", usec: "30000000"},
+		{name: "bad_pid", watchdogPID: "abc", usec: "30000000", wantErr: true},
+		{name: "bad_usec", usec: "30s", wantErr: true},
+		{name: "zero_usec", usec: "0", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			timeout, err := parseWatchdogEnv(pid, test.watchdogPID, test.usec)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %v", timeout)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseWatchdogEnv: %v", err)
+			}
+			if timeout != test.expected {
+				t.Fatalf("timeout = %v, expected %v", timeout, test.expected)
+			}
+		})
+	}
+}
+
+func TestNotify(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no unixgram sockets on windows")
+	}
+	t.Setenv("NOTIFY_SOCKET", "")
+	if sent, err := Notify("READY=1"); sent || err != nil {
+		t.Fatalf("Notify without a socket = %v, %v; expected nothing sent and no error", sent, err)
+	}
+
+	path := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+	t.Setenv("NOTIFY_SOCKET", path)
+
+	if sent, err := Notify("READY=1"); !sent || err != nil {
+		t.Fatalf("Notify = %v, %v; expected sent", sent, err)
+	}
+	buf := make([]byte, 64)
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if string(buf[:n]) != "READY=1" {
+		t.Fatalf("received %q, expected READY=1", buf[:n])
+	}
+}
@@ -20,15 +20,21 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
 	"github.com/Psiphon-Inc/conduit/cli/cmd"
+	"github.com/Psiphon-Inc/conduit/cli/internal/conduit"
+	"github.com/Psiphon-Inc/conduit/cli/internal/config"
 )
 
 func main() {
 	if err := cmd.Execute(); err != nil {
 		fmt.Fprintln(os.Stderr, err)
+		if errors.Is(err, conduit.ErrMustUpgrade) {
+			os.Exit(config.ExitMustUpgrade)
+		}
 		os.Exit(1)
 	}
 }